@@ -0,0 +1,278 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/ramoncl001/comet/ioc"
+	"github.com/ramoncl001/comet/log"
+	"github.com/ramoncl001/comet/rest"
+	"github.com/ramoncl001/comet/validation"
+)
+
+type argKind int
+
+const (
+	argRequest argKind = iota
+	argContext
+	argBody
+	argService
+	argSocket
+	argReader
+)
+
+type actionArg struct {
+	kind argKind
+	typ  reflect.Type
+}
+
+// action is the result of analyzing a controller method once at
+// registration, so that requests only have to fill in the arguments.
+type returnKind int
+
+const (
+	returnResponse returnKind = iota
+	returnError
+	returnValue
+)
+
+type action struct {
+	method  reflect.Method
+	args    []actionArg
+	returns returnKind
+	socket  bool
+	// readsBody is set for actions taking the request, whose Body field is
+	// filled before they run unless they stream it through an io.Reader.
+	readsBody bool
+}
+
+var (
+	requestType  = reflect.TypeOf(&rest.Request{})
+	responseType = reflect.TypeOf(rest.Response{})
+	contextType  = reflect.TypeOf((*context.Context)(nil)).Elem()
+	socketType   = reflect.TypeOf(&rest.WebSocket{})
+	errorType    = reflect.TypeOf((*error)(nil)).Elem()
+	readerType   = reflect.TypeOf((*io.Reader)(nil)).Elem()
+)
+
+// analyzeAction validates an action signature. Besides the request, actions
+// may take a context.Context, structs or pointers to structs bound from the
+// request with rest.BindTo, and interfaces or pointers resolved from the IoC
+// container. Services must be registered before the controller is mapped;
+// pointers to structs that are not registered are bound instead.
+// They return a rest.Response, an error, or a value and an error; errors are
+// mapped with rest.FromError. Websocket actions take a *rest.WebSocket
+// instead and return nothing or an error. An io.Reader parameter streams
+// the request body; otherwise actions taking the request find it read into
+// Body, except for GET and HEAD requests and multipart forms.
+// hasActionSignature reports whether a method takes or returns one of the
+// types only actions use, so a broken action is told apart from a plain
+// method such as GetName() string, which is left alone.
+func hasActionSignature(typ reflect.Type) bool {
+	for i := 1; i < typ.NumIn(); i++ {
+		if in := typ.In(i); in == socketType || in == readerType || in == contextType || isRequestType(in) {
+			return true
+		}
+	}
+
+	for i := 0; i < typ.NumOut(); i++ {
+		if out := typ.Out(i); out == errorType || out.ConvertibleTo(responseType) {
+			return true
+		}
+	}
+	return false
+}
+
+func analyzeAction(method reflect.Method) (*action, error) {
+	typ := method.Type
+	result := &action{method: method}
+	for i := 1; i < typ.NumIn(); i++ {
+		if typ.In(i) == socketType {
+			result.socket = true
+		}
+	}
+
+	if result.socket {
+		if typ.NumOut() > 1 || typ.NumOut() == 1 && typ.Out(0) != errorType {
+			return nil, errors.New("must return nothing or an error")
+		}
+	} else {
+		switch {
+		case typ.NumOut() == 1 && typ.Out(0) == errorType:
+			result.returns = returnError
+		case typ.NumOut() == 1 && typ.Out(0).ConvertibleTo(responseType):
+			result.returns = returnResponse
+		case typ.NumOut() == 2 && typ.Out(1) == errorType:
+			result.returns = returnValue
+		default:
+			return nil, errors.New("must return a rest.Response, an error, or a value and an error")
+		}
+	}
+
+	bodies := 0
+	for i := 1; i < typ.NumIn(); i++ {
+		in := typ.In(i)
+		switch {
+		case in == socketType:
+			result.args = append(result.args, actionArg{kind: argSocket, typ: in})
+		case isRequestType(in):
+			result.readsBody = true
+			result.args = append(result.args, actionArg{kind: argRequest, typ: in})
+		case in == readerType:
+			bodies++
+			result.args = append(result.args, actionArg{kind: argReader, typ: in})
+		case in == contextType:
+			result.args = append(result.args, actionArg{kind: argContext, typ: in})
+		case in.Kind() == reflect.Struct, in.Kind() == reflect.Ptr && in.Elem().Kind() == reflect.Struct && !ioc.Registered(in):
+			if err := validation.CheckRules(in); err != nil {
+				return nil, fmt.Errorf("parameter %d: %w", i, err)
+			}
+			bodies++
+			result.args = append(result.args, actionArg{kind: argBody, typ: in})
+		case in.Kind() == reflect.Interface || in.Kind() == reflect.Ptr:
+			if !ioc.Registered(in) {
+				return nil, fmt.Errorf("parameter %d has type %s, which is not registered as a service", i, in)
+			}
+			result.args = append(result.args, actionArg{kind: argService, typ: in})
+		default:
+			return nil, fmt.Errorf("parameter %d has unsupported type %s", i, in)
+		}
+	}
+
+	if bodies > 1 {
+		return nil, errors.New("can bind at most one request model or body reader")
+	}
+
+	if bodies > 0 {
+		result.readsBody = false
+	}
+
+	return result, nil
+}
+
+func isRequestType(t reflect.Type) bool {
+	return t == requestType || (t.Kind() == reflect.Ptr && t.Elem().Name() == "Request" && requestType.ConvertibleTo(t))
+}
+
+func (a *action) invoke(ctrl interface{}, req *rest.Request) rest.Response {
+	if a.readsBody && !a.socket && hasBody(req) {
+		if _, err := req.ReadBody(); err != nil {
+			return a.fail(req, err)
+		}
+	}
+
+	in := make([]reflect.Value, 0, len(a.args)+1)
+	in = append(in, reflect.ValueOf(ctrl))
+
+	for _, arg := range a.args {
+		switch arg.kind {
+		case argRequest:
+			in = append(in, reflect.ValueOf(req).Convert(arg.typ))
+		case argContext:
+			in = append(in, reflect.ValueOf(req.Context()))
+		case argBody:
+			model := arg.typ
+			if model.Kind() == reflect.Ptr {
+				model = model.Elem()
+			}
+
+			target := reflect.New(model)
+			if err := rest.BindTo(req, target.Interface()); err != nil {
+				return rest.InvalidInput(err)
+			}
+
+			if arg.typ.Kind() == reflect.Ptr {
+				in = append(in, target)
+			} else {
+				in = append(in, target.Elem())
+			}
+		case argService:
+			service, err := ioc.Resolve(req.Context(), arg.typ)
+			if err != nil {
+				log.FromContext(req.Context()).Error("service resolution failed", "action", a.method.Name, "service", arg.typ.String(), "error", err.Error())
+				return rest.Problem(http.StatusInternalServerError, "")
+			}
+			in = append(in, reflect.ValueOf(service))
+		case argReader:
+			in = append(in, reflect.ValueOf(req.BodyReader()))
+		case argSocket:
+			in = append(in, reflect.Value{})
+		}
+	}
+
+	if a.socket {
+		return a.upgrade(in, req)
+	}
+
+	out := a.method.Func.Call(in)
+	switch a.returns {
+	case returnError:
+		if !out[0].IsNil() {
+			return a.fail(req, out[0].Interface().(error))
+		}
+		return rest.NoContent()
+	case returnValue:
+		if !out[1].IsNil() {
+			return a.fail(req, out[1].Interface().(error))
+		}
+
+		if out[0].Type().ConvertibleTo(responseType) {
+			return out[0].Convert(responseType).Interface().(rest.Response)
+		}
+		return rest.Ok(out[0].Interface())
+	}
+	return out[0].Convert(responseType).Interface().(rest.Response)
+}
+
+// hasBody reports whether the request body should be read into Body.
+// Multipart forms are left to be streamed by the form parser.
+func hasBody(req *rest.Request) bool {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return false
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(http.Header(req.Headers).Get("Content-Type"))
+	return !strings.HasPrefix(mediaType, "multipart/")
+}
+
+func (a *action) fail(req *rest.Request, err error) rest.Response {
+	response := rest.FromError(err)
+	if response.Status >= http.StatusInternalServerError {
+		log.FromContext(req.Context()).Error("action failed", "action", a.method.Name, "error", err.Error())
+	}
+	return response
+}
+
+// upgrade runs a websocket action once the handshake is done. Its context
+// argument is the connection context, which ends when the socket closes.
+// Options set with rest.WithWebSocketOptions, e.g. by the WebSocketConfig
+// middleware of the action, replace the defaults.
+func (a *action) upgrade(in []reflect.Value, req *rest.Request) rest.Response {
+	opts := rest.DefaultWebSocketOptions
+	if configured, ok := rest.WebSocketOptionsFromContext(req.Context()); ok {
+		opts = configured
+	}
+
+	return rest.Upgrade(req, opts, func(ws *rest.WebSocket) error {
+		for i, arg := range a.args {
+			switch arg.kind {
+			case argSocket:
+				in[i+1] = reflect.ValueOf(ws)
+			case argContext:
+				in[i+1] = reflect.ValueOf(ws.Context())
+			}
+		}
+
+		out := a.method.Func.Call(in)
+		if len(out) == 1 && !out[0].IsNil() {
+			return out[0].Interface().(error)
+		}
+		return nil
+	})
+}
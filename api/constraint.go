@@ -0,0 +1,63 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+type constraint struct {
+	expr  string
+	parse func(string) (interface{}, bool)
+}
+
+var constraints = map[string]func(string) (interface{}, bool){
+	"": func(s string) (interface{}, bool) {
+		return s, true
+	},
+	"int": func(s string) (interface{}, bool) {
+		v, err := strconv.Atoi(s)
+		return v, err == nil
+	},
+	"uint": func(s string) (interface{}, bool) {
+		v, err := strconv.ParseUint(s, 10, 64)
+		return v, err == nil
+	},
+	"float": func(s string) (interface{}, bool) {
+		v, err := strconv.ParseFloat(s, 64)
+		return v, err == nil
+	},
+	"bool": func(s string) (interface{}, bool) {
+		v, err := strconv.ParseBool(s)
+		return v, err == nil
+	},
+	"uuid": func(s string) (interface{}, bool) {
+		v, err := uuid.Parse(s)
+		return v, err == nil
+	},
+	"alpha": regexConstraint(regexp.MustCompile(`^[A-Za-z]+$`)),
+	"alnum": regexConstraint(regexp.MustCompile(`^[A-Za-z0-9]+$`)),
+}
+
+// compileConstraint resolves a named constraint such as "int" or "uuid";
+// any other expression is compiled as an anchored regular expression.
+func compileConstraint(expr string) (*constraint, error) {
+	if parse, ok := constraints[expr]; ok {
+		return &constraint{expr: expr, parse: parse}, nil
+	}
+
+	rgx, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid constraint %q: %w", expr, err)
+	}
+
+	return &constraint{expr: expr, parse: regexConstraint(rgx)}, nil
+}
+
+func regexConstraint(rgx *regexp.Regexp) func(string) (interface{}, bool) {
+	return func(s string) (interface{}, bool) {
+		return s, rgx.MatchString(s)
+	}
+}
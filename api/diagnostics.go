@@ -0,0 +1,198 @@
+package api
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"slices"
+	"strings"
+)
+
+type RegistrationError struct {
+	Problems []string
+}
+
+func (e *RegistrationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "route registration failed with %d problem(s):", len(e.Problems))
+	for _, problem := range e.Problems {
+		b.WriteString("\n  - ")
+		b.WriteString(problem)
+	}
+	return b.String()
+}
+
+func (r *router) report(format string, args ...interface{}) {
+	r.problems = append(r.problems, fmt.Sprintf(format, args...))
+}
+
+func (r *router) validate() error {
+	if len(r.problems) == 0 {
+		return nil
+	}
+	return &RegistrationError{Problems: r.problems}
+}
+
+// routeShape identifies the tree position of a route: parameter names and
+// constraints are dropped, so "/users/:id{int}" and "/users/:name" share a
+// shape. Routes of the same shape are told apart by ambiguous.
+func routeShape(method, template string) string {
+	parts := strings.Split(template, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") {
+			parts[i] = ":"
+		} else if strings.HasPrefix(part, "*") {
+			parts[i] = "*"
+		}
+	}
+	return method + " " + strings.Join(parts, "/")
+}
+
+// ambiguous reports whether two routes of the same shape can match a path
+// with no precedence between them. Constrained parameters are tried before
+// plain ones, so a parameter only collides with one of the same kind: two
+// plain ones always do, two constrained ones when they accept a common
+// value.
+func ambiguous(a, b *route) bool {
+	exprsA, exprsB := constraintExprs(a.PathPattern), constraintExprs(b.PathPattern)
+	for i := range exprsA {
+		x, y := exprsA[i], exprsB[i]
+		if (x == "") != (y == "") {
+			return false
+		}
+
+		if x != y && !overlap(x, y) {
+			return false
+		}
+	}
+	return true
+}
+
+func constraintExprs(template string) []string {
+	exprs := make([]string, 0)
+	for _, part := range strings.Split(template, "/") {
+		if strings.HasPrefix(part, ":") {
+			_, expr := splitParam(part[1:])
+			exprs = append(exprs, expr)
+		} else if strings.HasPrefix(part, "*") {
+			exprs = append(exprs, "")
+		}
+	}
+	return exprs
+}
+
+// overlap probes two constraints with sample values of each, e.g. "0" for
+// "int" and "000" for "[0-9]{3}". Sharing no sample is taken as disjoint,
+// so overlaps the samples miss go unreported: "[a-z]x" and "m[a-z]" both
+// accept "mx", but character classes are only sampled at their bounds.
+// Such routes are registered and the one registered first wins.
+func overlap(x, y string) bool {
+	cx, err := compileConstraint(x)
+	if err != nil {
+		return false
+	}
+
+	cy, err := compileConstraint(y)
+	if err != nil {
+		return false
+	}
+
+	for _, sample := range slices.Concat(constraintSamples(x), constraintSamples(y)) {
+		_, okX := cx.parse(sample)
+		_, okY := cy.parse(sample)
+		if okX && okY {
+			return true
+		}
+	}
+	return false
+}
+
+var namedSamples = map[string][]string{
+	"int":   {"0", "1", "-1", "+1"},
+	"uint":  {"0", "1"},
+	"float": {"0", "1", "-1", "1.5", "1e3", "inf", "nan"},
+	"bool":  {"0", "1", "t", "f", "T", "F", "true", "false", "TRUE", "FALSE", "True", "False"},
+	"uuid":  {"00000000-0000-0000-0000-000000000000", "00000000000000000000000000000000"},
+	"alpha": {"a", "A", "z", "Z"},
+	"alnum": {"a", "A", "0", "9"},
+}
+
+func constraintSamples(expr string) []string {
+	if samples, ok := namedSamples[expr]; ok {
+		return samples
+	}
+
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	return regexSamples(re.Simplify())
+}
+
+const maxSamples = 64
+
+// regexSamples returns short strings matched by re: each alternative and
+// the bounds of each character class contribute one.
+func regexSamples(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+	case syntax.OpCharClass:
+		samples := make([]string, 0, len(re.Rune))
+		for _, r := range re.Rune {
+			samples = append(samples, string(r))
+		}
+		return limitSamples(samples)
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return []string{"a", "0"}
+	case syntax.OpCapture:
+		return regexSamples(re.Sub[0])
+	case syntax.OpStar, syntax.OpQuest:
+		return limitSamples(append([]string{""}, regexSamples(re.Sub[0])...))
+	case syntax.OpPlus:
+		return regexSamples(re.Sub[0])
+	case syntax.OpRepeat:
+		sub := regexSamples(re.Sub[0])
+		samples := make([]string, 0, len(sub)+1)
+		if re.Min == 0 {
+			samples = append(samples, "")
+		}
+		for _, s := range sub {
+			samples = append(samples, strings.Repeat(s, max(re.Min, 1)))
+		}
+		return limitSamples(samples)
+	case syntax.OpConcat:
+		samples := []string{""}
+		for _, sub := range re.Sub {
+			next := make([]string, 0, maxSamples)
+			for _, prefix := range samples {
+				for _, s := range regexSamples(sub) {
+					next = append(next, prefix+s)
+				}
+			}
+			samples = limitSamples(next)
+		}
+		return samples
+	case syntax.OpAlternate:
+		samples := make([]string, 0, len(re.Sub))
+		for _, sub := range re.Sub {
+			samples = append(samples, regexSamples(sub)...)
+		}
+		return limitSamples(samples)
+	default:
+		return []string{""}
+	}
+}
+
+func limitSamples(samples []string) []string {
+	if len(samples) > maxSamples {
+		return samples[:maxSamples]
+	}
+	return samples
+}
+
+func (rt *route) String() string {
+	if rt.controller == nil {
+		return fmt.Sprintf("%s %s", rt.Method, rt.PathPattern)
+	}
+	return fmt.Sprintf("%s %s (%s.%s)", rt.Method, rt.PathPattern, rt.controller.name, rt.Handler.Name)
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"io/fs"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/ramoncl001/comet/ioc"
+	"github.com/ramoncl001/comet/middleware"
+	"github.com/ramoncl001/comet/rest"
+)
+
+type RouteGroup interface {
+	MapController(controller interface{})
+	UseMiddleware(m middleware.Middleware)
+	UsePolicies(policies ...rest.Policy)
+	Group(prefix string, middlewares ...middleware.Middleware) RouteGroup
+	ServeStatic(prefix string, fsys fs.FS, opts StaticOptions)
+}
+
+type routeGroup struct {
+	router      *router
+	parent      *routeGroup
+	prefix      string
+	middlewares []middleware.Middleware
+	policies    []rest.Policy
+}
+
+func newRouteGroup(router *router, parent *routeGroup, prefix string, middlewares ...middleware.Middleware) *routeGroup {
+	prefix = "/" + strings.Trim(prefix, "/")
+	if parent != nil {
+		prefix = strings.TrimSuffix(parent.prefix, "/") + prefix
+	}
+
+	return &routeGroup{
+		router:      router,
+		parent:      parent,
+		prefix:      strings.TrimSuffix(prefix, "/"),
+		middlewares: middlewares,
+	}
+}
+
+func (g *routeGroup) MapController(controller interface{}) {
+	typ := reflect.TypeOf(controller)
+	if typ.Kind() != reflect.Func || typ.NumOut() == 0 {
+		panic(typ.String() + " is not a controller constructor function")
+	}
+
+	key := typ.Out(0)
+	ioc.RegisterKeyedScoped[rest.ControllerBase](controller, controllerName(key))
+	ioc.RegisterKeyedScoped[rest.ControllerBase](controller, key)
+	ctrl, err := ioc.ResolveKeyedScoped[rest.ControllerBase](context.Background(), key)
+	if err != nil {
+		panic(err)
+	}
+
+	g.router.register(ctrl, key, g)
+}
+
+func (g *routeGroup) UseMiddleware(m middleware.Middleware) {
+	g.middlewares = append(g.middlewares, m)
+}
+
+func (g *routeGroup) UsePolicies(policies ...rest.Policy) {
+	g.policies = append(g.policies, policies...)
+}
+
+func (g *routeGroup) Group(prefix string, middlewares ...middleware.Middleware) RouteGroup {
+	return newRouteGroup(g.router, g, prefix, middlewares...)
+}
+
+func (g *routeGroup) path(template string) string {
+	return g.prefix + template
+}
+
+func (g *routeGroup) allMiddlewares() []middleware.Middleware {
+	if g.parent == nil {
+		return g.middlewares
+	}
+	return slices.Concat(g.parent.allMiddlewares(), g.middlewares)
+}
+
+// commonGroup returns the innermost group containing both a and b.
+func commonGroup(a, b *routeGroup) *routeGroup {
+	for outer := a; outer != nil; outer = outer.parent {
+		for inner := b; inner != nil; inner = inner.parent {
+			if outer == inner {
+				return outer
+			}
+		}
+	}
+	return nil
+}
+
+func (g *routeGroup) allPolicies() []rest.Policy {
+	if g.parent == nil {
+		return g.policies
+	}
+	return slices.Concat(g.parent.allPolicies(), g.policies)
+}
+
+// controllerName is the type name controllers are also registered under, so
+// applications can resolve them with ioc.ResolveKeyedScoped by name.
+func controllerName(typ reflect.Type) string {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ.Name()
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/ramoncl001/comet/rest"
+)
+
+type RouteInfo struct {
+	Method      string       `json:"method"`
+	Template    string       `json:"template"`
+	Controller  string       `json:"controller,omitempty"`
+	Action      string       `json:"action"`
+	Params      []string     `json:"params"`
+	Policies    []PolicyInfo `json:"policies,omitempty"`
+	Middlewares []string     `json:"middlewares,omitempty"`
+}
+
+type PolicyInfo struct {
+	Authorizer string `json:"authorizer"`
+	Value      string `json:"value"`
+}
+
+func (r *router) routes() []RouteInfo {
+	infos := make([]RouteInfo, 0, len(r.keys))
+	for _, shaped := range r.keys {
+		for _, rt := range shaped {
+			info := RouteInfo{
+				Method:   rt.Method,
+				Template: rt.PathPattern,
+				Action:   rt.Handler.Name,
+				Params:   rt.ParamNames,
+			}
+
+			policies := rt.group.allPolicies()
+			if rt.controller != nil {
+				info.Controller = fmt.Sprint(rt.controller.key)
+				policies = slices.Concat(policies, actionPolicies(rt.controller.policies, rt.Handler.Name))
+			}
+
+			for _, policy := range policies {
+				info.Policies = append(info.Policies, PolicyInfo{
+					Authorizer: funcName(policy.Validation),
+					Value:      fmt.Sprint(policy.Value),
+				})
+			}
+
+			for _, m := range slices.Concat(rt.group.allMiddlewares(), rt.middlewares) {
+				info.Middlewares = append(info.Middlewares, funcName(m))
+			}
+
+			infos = append(infos, info)
+		}
+	}
+
+	slices.SortFunc(infos, func(a, b RouteInfo) int {
+		if c := strings.Compare(a.Template, b.Template); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Method, b.Method)
+	})
+
+	return infos
+}
+
+func (r *router) mapRouteTable(group *routeGroup, path string) {
+	err := r.handle(group, "GET", path, "RouteTable", func(req *rest.Request) rest.Response {
+		return rest.Ok(r.routes())
+	})
+	if err != nil {
+		r.report("%v", err)
+	}
+}
+
+func funcName(fn interface{}) string {
+	value := reflect.ValueOf(fn)
+	if value.Kind() != reflect.Func || value.IsNil() {
+		return ""
+	}
+
+	f := runtime.FuncForPC(value.Pointer())
+	if f == nil {
+		return ""
+	}
+	return f.Name()
+}
@@ -2,12 +2,15 @@ package api
 
 import (
 	"fmt"
+	"net/http"
 	"reflect"
 	"regexp"
+	"slices"
 	"strings"
 	"unicode"
 
 	"github.com/ramoncl001/comet/ioc"
+	"github.com/ramoncl001/comet/middleware"
 	"github.com/ramoncl001/comet/rest"
 )
 
@@ -16,190 +19,365 @@ type routeHandler struct {
 	Name     string
 }
 
+// routeVerbs are the methods explicit routes may be mapped to, once LIST
+// and WS are translated to GET.
+var routeVerbs = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
 type route struct {
 	Method      string
 	PathPattern string
 	Handler     routeHandler
-	PathParts   []string
 	ParamNames  []string
+	controller  *controller
+	group       *routeGroup
+	middlewares []middleware.Middleware
 }
 
 type controller struct {
-	name          string
-	basePath      string
-	staticRoutes  map[string]*routeHandler
-	dynamicRoutes []route
+	name        string
+	qualified   string
+	key         interface{}
+	basePath    string
+	policies    rest.PoliciesConfig
+	middlewares middleware.Config
+	routes      []*route
 }
 
 type router struct {
-	controllers map[string]controller
+	controllers map[string]*controller
+	trees       map[string]*node
+	keys        map[string][]*route
+	names       map[string][]*route
+	problems    []string
 }
 
-func (r *router) register(ctrl rest.ControllerBase) {
+func (r *router) register(ctrl rest.ControllerBase, key interface{}, group *routeGroup) {
 	controllerType := reflect.TypeOf(ctrl)
 
-	controller := controller{
-		staticRoutes:  make(map[string]*routeHandler, 0),
-		dynamicRoutes: make([]route, 0),
+	elemType := controllerType
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+
+	controller := &controller{
+		name:      elemType.Name(),
+		qualified: elemType.String(),
+		key:       key,
+		policies:  ctrl.Policies(),
+		routes:    make([]*route, 0),
+	}
+
+	if provider, ok := ctrl.(middleware.Provider); ok {
+		controller.middlewares = provider.Middlewares()
+		for methodName := range controller.middlewares {
+			if _, ok := controllerType.MethodByName(methodName); !ok && methodName != "*" {
+				r.report("%s.Middlewares references unknown method %s", controller.name, methodName)
+			}
+		}
 	}
 
 	basePath := ""
 	if ctrl.Route() == "" {
-		basePath = getRouteName(controllerType.Name())
+		basePath = getRouteName(elemType.Name())
 	} else {
 		basePath = ctrl.Route()
 	}
 
-	controller.basePath = basePath
-	name := strings.ReplaceAll(basePath, "/", "")
+	controller.basePath = group.path(basePath)
+	if existing, ok := r.controllers[controller.basePath]; ok && existing.key != controller.key {
+		r.report("controllers %s and %s are both mapped to %s", existing.name, controller.name, controller.basePath)
+		return
+	}
+
+	r.controllers[controller.basePath] = controller
+
+	explicit := make(rest.RoutesConfig)
+	if provider, ok := ctrl.(rest.RouteProvider); ok {
+		explicit = provider.Routes()
+	}
+
+	names := make([]string, 0, len(explicit))
+	for methodName := range explicit {
+		names = append(names, methodName)
+	}
+	slices.Sort(names)
+
+	for _, methodName := range names {
+		definition := explicit[methodName]
+
+		method, ok := controllerType.MethodByName(methodName)
+		if !ok {
+			r.report("%s.Routes references unknown method %s", controller.name, methodName)
+			continue
+		}
+
+		action, err := analyzeAction(method)
+		if err != nil {
+			r.report("%s.%s %v", controller.name, methodName, err)
+			continue
+		}
+
+		verb := rest.RequestMethod(strings.ToUpper(definition.Method.String())).Method()
+		if !slices.Contains(routeVerbs, verb) {
+			r.report("%s.%s is mapped to unknown method %q", controller.name, methodName, definition.Method)
+			continue
+		}
+
+		if action.socket && verb != http.MethodGet {
+			r.report("%s.%s is a websocket action and must be mapped to GET or WS", controller.name, methodName)
+			continue
+		}
+
+		path := group.path(joinPath(basePath, definition.Path))
+		if err := r.addRoute(newRoute(controller, group, action, verb, path)); err != nil {
+			r.report("%v", err)
+		}
+	}
 
 	for i := 0; i < controllerType.NumMethod(); i++ {
 		method := controllerType.Method(i)
+		if _, ok := explicit[method.Name]; ok {
+			continue
+		}
+
 		if !isRequestMethod(method) {
 			continue
 		}
 
+		action, err := analyzeAction(method)
+		if err != nil {
+			if looksLikeAction(method.Name) && hasActionSignature(method.Type) {
+				r.report("%s.%s looks like an action but %v", controller.name, method.Name, err)
+			}
+			continue
+		}
+
 		invariantName := strings.ToUpper(method.Name)
-		methodMap := []rest.RequestMethod{rest.GET, rest.POST, rest.DELETE, rest.PATCH, rest.POST, rest.PUT, rest.LIST}
+		methodMap := []rest.RequestMethod{rest.GET, rest.POST, rest.DELETE, rest.PATCH, rest.PUT, rest.LIST, rest.WS}
 
 		for _, prefix := range methodMap {
 			if strings.HasPrefix(invariantName, prefix.String()) {
-				path := getMethodPath(basePath, method.Name)
-				httpMethod := prefix.Method()
-
-				handler := func(req *rest.Request) rest.Response {
-					n := controllerType.Name()
-					ctrl, err := ioc.ResolveKeyedScoped[rest.ControllerBase](req.Context(), n)
-					if err != nil {
-						return rest.Error("error getting controller")
-					}
-
-					controller := reflect.ValueOf(ctrl)
-					request := reflect.ValueOf(req)
-
-					responses := method.Func.Call([]reflect.Value{controller, request})
-					result := responses[0].Interface().(rest.Response)
-					return result
+				if prefix == rest.WS && !action.socket && looksLikeAction(method.Name) {
+					r.report("%s.%s looks like a websocket action but does not take a *rest.WebSocket", controller.name, method.Name)
+					break
 				}
 
-				if !strings.Contains(path, ":") {
-					key := fmt.Sprintf("%s:%s", httpMethod, path)
-					controller.staticRoutes[key] = &routeHandler{
-						Function: handler,
-						Name:     method.Name,
-					}
+				if prefix != rest.WS && action.socket {
+					r.report("%s.%s takes a *rest.WebSocket but is not a Ws action", controller.name, method.Name)
 					break
 				}
 
-				parts := strings.Split(path, "/")
-				params := make([]string, 0)
-
-				for _, part := range parts {
-					if strings.HasPrefix(part, ":") {
-						params = append(params, part[1:])
-					}
+				if prefix == rest.WS && !action.socket {
+					break
 				}
 
-				controller.dynamicRoutes = append(controller.dynamicRoutes, route{
-					Method:      httpMethod,
-					PathPattern: path,
-					Handler: struct {
-						Function rest.RequestHandler
-						Name     string
-					}{
-						Function: handler,
-						Name:     method.Name,
-					},
-					PathParts:  parts,
-					ParamNames: params,
-				})
-
+				path := group.path(getMethodPath(basePath, method.Name))
+				if err := r.addRoute(newRoute(controller, group, action, prefix.Method(), path)); err != nil {
+					r.report("%v", err)
+				}
 				break
 			}
 		}
 	}
+}
+
+func newRoute(controller *controller, group *routeGroup, action *action, httpMethod, path string) *route {
+	handler := func(req *rest.Request) rest.Response {
+		ctrl, err := ioc.ResolveKeyedScoped[rest.ControllerBase](req.Context(), controller.key)
+		if err != nil {
+			return rest.Problem(http.StatusInternalServerError, "error getting controller")
+		}
+
+		return action.invoke(ctrl, req)
+	}
+
+	name := action.method.Name
+	return &route{
+		Method:      httpMethod,
+		PathPattern: path,
+		Handler: routeHandler{
+			Function: handler,
+			Name:     name,
+		},
+		ParamNames:  getParamNames(path),
+		controller:  controller,
+		group:       group,
+		middlewares: slices.Concat(controller.middlewares["*"], controller.middlewares[name]),
+	}
+}
 
-	controller.name = controllerType.Name()
-	r.controllers[name] = controller
+func (r *router) handle(group *routeGroup, method, path, name string, handler rest.RequestHandler) error {
+	path = group.path(path)
+	return r.addRoute(&route{
+		Method:      method,
+		PathPattern: path,
+		Handler: routeHandler{
+			Function: handler,
+			Name:     name,
+		},
+		ParamNames: getParamNames(path),
+		group:      group,
+	})
 }
 
-func (r *router) Handle(req *rest.Request) rest.Response {
-	path := req.Url.Path
+func (r *router) addRoute(rt *route) error {
+	if err := validateTemplate(rt.PathPattern); err != nil {
+		return fmt.Errorf("%s: %w", rt, err)
+	}
+
+	key := routeShape(rt.Method, rt.PathPattern)
+	for _, existing := range r.keys[key] {
+		if existing.PathPattern == rt.PathPattern {
+			return fmt.Errorf("duplicate route %s, already registered by %s", rt, existing)
+		}
 
-	name := strings.Split(path, "/")[1]
-	controller := r.controllers[name]
+		if ambiguous(existing, rt) {
+			return fmt.Errorf("ambiguous route %s shadows %s", rt, existing)
+		}
+	}
 
-	str := fmt.Sprintf("%s:%s", req.Method, path)
+	names := rt.names()
+	if existing := r.names[names[len(names)-1]]; rt.controller != nil && len(existing) > 0 {
+		return fmt.Errorf("route %s is named %s, already used by %s", rt, names[len(names)-1], existing[0])
+	}
 
-	var handler *routeHandler
-	handler, ok := controller.staticRoutes[str]
+	tree, ok := r.trees[rt.Method]
 	if !ok {
-		for _, route := range controller.dynamicRoutes {
-			if route.Method != req.Method {
-				continue
-			}
+		tree = newTree()
+		r.trees[rt.Method] = tree
+	}
 
-			if params, ok := r.matchPath(route, path); ok {
-				req.PathParams = params
-				handler = &route.Handler
-			}
-		}
+	if err := tree.insert(rt.PathPattern, rt); err != nil {
+		return fmt.Errorf("%s: %w", rt, err)
 	}
 
-	if handler == nil {
-		return rest.NotFound()
+	r.keys[key] = append(r.keys[key], rt)
+	for _, name := range names {
+		r.names[name] = append(r.names[name], rt)
 	}
+	if rt.controller != nil {
+		rt.controller.routes = append(rt.controller.routes, rt)
+	}
+	return nil
+}
 
-	ctrl, err := ioc.ResolveKeyedScoped[rest.ControllerBase](req.Context(), controller.name)
-	if err != nil {
-		return rest.NotFound()
+func (r *router) lookup(method, path string) (*route, map[string]string, map[string]interface{}) {
+	tree, ok := r.trees[method]
+	if !ok {
+		return nil, nil, nil
+	}
+
+	rt, values := tree.match(path, make([]paramValue, 0, 4))
+	if rt == nil {
+		return nil, nil, nil
+	}
+
+	params := make(map[string]string, len(rt.ParamNames))
+	typed := make(map[string]interface{}, len(rt.ParamNames))
+	for i, name := range rt.ParamNames {
+		params[name] = values[i].raw
+		typed[name] = values[i].value
 	}
 
-	authorizeMap := make(map[interface{}]rest.AuthorizerFunction)
-	config := make([]rest.Policy, 0)
+	return rt, params, typed
+}
+
+// allowedMethods lists the methods with a route matching path, along with
+// the innermost group shared by those routes.
+func (r *router) allowedMethods(path string) ([]string, *routeGroup) {
+	allowed := make([]string, 0, len(r.trees)+2)
+	var group *routeGroup
+	for method, tree := range r.trees {
+		rt, _ := tree.match(path, nil)
+		if rt == nil {
+			continue
+		}
+
+		if group == nil {
+			group = rt.group
+		} else {
+			group = commonGroup(group, rt.group)
+		}
 
-	globalPolicies := ctrl.Policies()["*"]
-	if globalPolicies != nil {
-		config = append(config, globalPolicies...)
+		allowed = append(allowed, method)
+		if method == http.MethodGet {
+			allowed = append(allowed, http.MethodHead)
+		}
 	}
 
-	handlerPolicies := ctrl.Policies()[handler.Name]
-	if handlerPolicies != nil {
-		config = append(config, handlerPolicies...)
+	if len(allowed) == 0 {
+		return nil, nil
 	}
 
-	for _, val := range config {
-		authorizeMap[val.Value] = val.Validation
+	if !slices.Contains(allowed, http.MethodOptions) {
+		allowed = append(allowed, http.MethodOptions)
 	}
 
-	resultHandler := chainAuthorizations(handler.Function, authorizeMap)
-	return resultHandler(req)
+	slices.Sort(allowed)
+	return slices.Compact(allowed), group
 }
 
-func (r *router) matchPath(route route, path string) (map[string]string, bool) {
-	pathParts := strings.Split(path, "/")
+func (r *router) Handle(req *rest.Request) rest.Response {
+	route, params, values := r.lookup(req.Method, req.Url.Path)
+	if route == nil && req.Method == http.MethodHead {
+		route, params, values = r.lookup(http.MethodGet, req.Url.Path)
+	}
+
+	if route == nil {
+		allowed, group := r.allowedMethods(req.Url.Path)
+		if len(allowed) == 0 {
+			return rest.NotFound()
+		}
 
-	if len(pathParts) != len(route.PathParts) {
-		return nil, false
+		allow := strings.Join(allowed, ", ")
+		answer := func(req *rest.Request) rest.Response {
+			if req.Method == http.MethodOptions {
+				return rest.NoContent().WithHeader("Allow", allow)
+			}
+			return rest.MethodNotAllowed().WithHeader("Allow", allow)
+		}
+		return chain(answer, group.allMiddlewares()...)(req)
 	}
 
-	params := make(map[string]string)
+	req = req.WithContext(rest.WithRouteTemplate(req.Context(), route.PathPattern))
+	req.PathParams = params
+	req.PathValues = values
+	handler := &route.Handler
 
-	for i, part := range route.PathParts {
-		if strings.HasPrefix(part, ":") {
-			paramName := part[1:]
-			params[paramName] = pathParts[i]
-		} else if part != pathParts[i] {
-			return nil, false
+	config := route.group.allPolicies()
+	if route.controller != nil {
+		ctrl, err := ioc.ResolveKeyedScoped[rest.ControllerBase](req.Context(), route.controller.key)
+		if err != nil {
+			return rest.NotFound()
 		}
+
+		config = slices.Concat(config, actionPolicies(ctrl.Policies(), handler.Name))
 	}
 
-	return params, true
+	resultHandler := chain(handler.Function, route.middlewares...)
+	resultHandler = chainAuthorizations(resultHandler, config)
+	resultHandler = chain(resultHandler, route.group.allMiddlewares()...)
+	return resultHandler(req)
+}
+
+func actionPolicies(policies rest.PoliciesConfig, name string) []rest.Policy {
+	return slices.Concat(policies["*"], policies[name])
 }
 
 func newRouter() *router {
 	return &router{
-		controllers: make(map[string]controller),
+		controllers: make(map[string]*controller),
+		trees:       make(map[string]*node),
+		keys:        make(map[string][]*route),
+		names:       make(map[string][]*route),
 	}
 }
 
@@ -208,50 +386,33 @@ func isRequestMethod(method reflect.Method) bool {
 		return false
 	}
 
-	validPrefixes := []rest.RequestMethod{rest.GET, rest.POST, rest.PUT, rest.PATCH, rest.DELETE, rest.LIST}
+	validPrefixes := []rest.RequestMethod{rest.GET, rest.POST, rest.PUT, rest.PATCH, rest.DELETE, rest.LIST, rest.WS}
 
 	methodName := strings.ToUpper(method.Name)
-	matchPrefix := false
 	for _, prefix := range validPrefixes {
 		if strings.HasPrefix(methodName, prefix.String()) {
-			matchPrefix = true
-			break
+			return true
 		}
 	}
 
-	if !matchPrefix {
-		return false
-	}
-
-	if method.Type.NumIn() != 2 {
-		return false
-	}
-
-	reqType := method.Type.In(1)
-	if reqType.Kind() != reflect.Ptr {
-		return false
-	}
-
-	reqType = reqType.Elem()
-
-	if method.Type.NumOut() != 1 {
-		return false
-	}
-
-	respType := method.Type.Out(0)
-	if respType.Kind() == reflect.Ptr {
-		respType = respType.Elem()
-	}
+	return false
+}
 
-	if respType != reflect.TypeOf(rest.Response{}) {
-		return false
+// looksLikeAction reports whether name is a verb prefix followed by the end
+// of the name or an upper-case letter, e.g. GetByID or Post, but not Getter.
+func looksLikeAction(name string) bool {
+	verbs := []string{"Get", "Post", "Put", "Patch", "Delete", "List", "Ws"}
+	for _, verb := range verbs {
+		remainder, ok := strings.CutPrefix(name, verb)
+		if ok && (remainder == "" || unicode.IsUpper(rune(remainder[0]))) {
+			return true
+		}
 	}
-
-	return reqType.Name() == "Request" && strings.Contains(reqType.PkgPath(), "github.com/ramoncl001/comet")
+	return false
 }
 
 func getMethodPath(basePath, methodName string) string {
-	httpMethods := []string{"Get", "Post", "Put", "Delete", "Patch", "List"}
+	httpMethods := []string{"Get", "Post", "Put", "Delete", "Patch", "List", "Ws"}
 	for _, prefix := range httpMethods {
 		if strings.HasPrefix(methodName, prefix) {
 			methodName = strings.TrimPrefix(methodName, prefix)
@@ -297,9 +458,63 @@ func getMethodPath(basePath, methodName string) string {
 	return completed
 }
 
-func chainAuthorizations(handler rest.RequestHandler, authorizers rest.AuthorizationMap) rest.RequestHandler {
-	for key, function := range authorizers {
-		handler = function(handler, key)
+func joinPath(basePath, template string) string {
+	if strings.HasPrefix(template, "/") {
+		return template
+	}
+
+	if template == "" {
+		return basePath
+	}
+
+	return strings.TrimSuffix(basePath, "/") + "/" + template
+}
+
+// validateTemplate rejects templates the tree would otherwise take
+// literally, such as "/foo:bar" or unbalanced constraint braces.
+func validateTemplate(template string) error {
+	parts := strings.Split(template, "/")
+	for i, part := range parts {
+		switch {
+		case strings.HasPrefix(part, ":"):
+			if _, err := paramEnd(part); err != nil {
+				return err
+			}
+		case strings.HasPrefix(part, "*"):
+			if part == "*" {
+				return fmt.Errorf("empty catch-all name in %q", template)
+			}
+
+			if i != len(parts)-1 {
+				return fmt.Errorf("catch-all parameter must be the last segment in %q", template)
+			}
+		case strings.ContainsAny(part, ":*"):
+			return fmt.Errorf("parameter must start a path segment in %q", template)
+		case strings.ContainsAny(part, "{}"):
+			return fmt.Errorf("unexpected constraint outside a parameter in %q", template)
+		}
+	}
+	return nil
+}
+
+func getParamNames(path string) []string {
+	params := make([]string, 0)
+	for _, part := range strings.Split(path, "/") {
+		if strings.HasPrefix(part, ":") {
+			name, _ := splitParam(part[1:])
+			params = append(params, name)
+		} else if strings.HasPrefix(part, "*") {
+			params = append(params, part[1:])
+		}
+	}
+	return params
+}
+
+// chainAuthorizations wraps handler so policies run in order, the group
+// ones before the controller ones. Policies sharing a value all run.
+func chainAuthorizations(handler rest.RequestHandler, policies []rest.Policy) rest.RequestHandler {
+	for i := len(policies) - 1; i >= 0; i-- {
+		handler = policies[i].Validation(handler, policies[i].Value)
 	}
 	return handler
 }
@@ -1,9 +1,10 @@
 package api
 
 import (
-	"context"
 	"fmt"
+	"io/fs"
 	"net/http"
+	"net/url"
 	"reflect"
 	"strings"
 	"unicode"
@@ -15,13 +16,20 @@ import (
 	"github.com/ramoncl001/comet/security"
 	"github.com/ramoncl001/comet/security/authentication"
 	"github.com/ramoncl001/comet/security/authentication/jwt"
+	"github.com/ramoncl001/comet/serialization"
 	"gorm.io/gorm"
 )
 
 type ApiServer interface {
 	MapController(controller interface{})
+	Group(prefix string, middlewares ...middleware.Middleware) RouteGroup
+	ServeStatic(prefix string, fsys fs.FS, opts StaticOptions)
 	UseDatabaseContext(dialector gorm.Dialector, args ...gorm.Option)
 	UseMiddleware(m middleware.Middleware)
+	UseJSONOptions(opts serialization.JSONOptions)
+	Routes() []RouteInfo
+	URLFor(name string, params map[string]string, query url.Values) (string, error)
+	MapRouteTable(path string)
 	AddJWTAuthentication(mg interface{}, provider jwt.JwtProvider, config jwt.JwtConfigurations, userConfig security.UserConfig)
 	//UseAuthorization()
 	//UseAuthentication()
@@ -32,13 +40,18 @@ type apiServer struct {
 	ApiServer
 	server      *http.ServeMux
 	router      *router
+	root        *routeGroup
 	middlewares []middleware.Middleware
 }
 
 func CreateServer() ApiServer {
+	router := newRouter()
+	ioc.RegisterSingleton[rest.URLGenerator](router)
+
 	return &apiServer{
 		server: http.NewServeMux(),
-		router: newRouter(),
+		router: router,
+		root:   newRouteGroup(router, nil, ""),
 	}
 }
 
@@ -56,45 +69,56 @@ func (srv *apiServer) AddJWTAuthentication(mg interface{}, provider jwt.JwtProvi
 }
 
 func (srv *apiServer) MapController(controller interface{}) {
-	typ := reflect.TypeOf(controller).Out(0)
-	if typ.Kind() == reflect.Ptr {
-		typ = typ.Elem()
-	}
+	srv.root.MapController(controller)
+}
 
-	name := typ.Name()
-	ioc.RegisterKeyedScoped[rest.ControllerBase](controller, name)
-	ctrl, err := ioc.ResolveKeyedScoped[rest.ControllerBase](context.Background(), typ.Name())
-	if err != nil {
-		panic(err)
-	}
+func (srv *apiServer) Group(prefix string, middlewares ...middleware.Middleware) RouteGroup {
+	return srv.root.Group(prefix, middlewares...)
+}
+
+func (srv *apiServer) ServeStatic(prefix string, fsys fs.FS, opts StaticOptions) {
+	srv.root.ServeStatic(prefix, fsys, opts)
+}
+
+func (srv *apiServer) Routes() []RouteInfo {
+	return srv.router.routes()
+}
 
-	srv.router.register(ctrl)
+func (srv *apiServer) URLFor(name string, params map[string]string, query url.Values) (string, error) {
+	return srv.router.URLFor(name, params, query)
+}
+
+func (srv *apiServer) MapRouteTable(path string) {
+	srv.router.mapRouteTable(srv.root, path)
 }
 
 func (srv *apiServer) UseMiddleware(m middleware.Middleware) {
 	srv.middlewares = append(srv.middlewares, m)
 }
 
+func (srv *apiServer) UseJSONOptions(opts serialization.JSONOptions) {
+	serialization.ConfigureJSON(opts)
+}
+
 func (stc *apiServer) UseDatabaseContext(dialector gorm.Dialector, args ...gorm.Option) {
 	ctx := data.NewDatabaseContext(dialector, args...)
 	ioc.RegisterSingleton(ctx)
 }
 
 func (srv *apiServer) Run(addr string) error {
+	if err := srv.router.validate(); err != nil {
+		return err
+	}
+
 	fmt.Printf("Running server in %s...\n", addr)
 	fmt.Println("Routes:")
 
-	for _, controller := range srv.router.controllers {
-		fmt.Printf("[%s]\n", controller.name)
-		for _, route := range controller.dynamicRoutes {
-			fmt.Printf("[%s]: %s\n", route.Method, route.PathPattern)
-		}
-
-		for key := range controller.staticRoutes {
-			method := strings.Split(key, ":")[0]
-			path := strings.Replace(key, method+":", "", 1)
-			fmt.Printf("[%s]: %s\n", method, path+"/")
+	for _, route := range srv.Routes() {
+		if route.Controller == "" {
+			fmt.Printf("[%s]: %s\n", route.Method, route.Template)
+			continue
 		}
+		fmt.Printf("[%s]: %s -> %s.%s\n", route.Method, route.Template, route.Controller, route.Action)
 	}
 
 	middlewares := chain(srv.router.Handle, srv.middlewares...)
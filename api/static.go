@@ -0,0 +1,301 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	pathpkg "path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ramoncl001/comet/rest"
+)
+
+type StaticOptions struct {
+	// Index is served for directory requests. Defaults to "index.html".
+	Index string
+	// SPAFallback serves the root index for unknown paths without a file
+	// extension, so client-side routes can be deep-linked.
+	SPAFallback bool
+	// Precompressed serves "<file>.gz" when it exists and the client
+	// accepts gzip.
+	Precompressed bool
+	// MaxAge sets the Cache-Control max-age when non-zero.
+	MaxAge time.Duration
+}
+
+type staticFiles struct {
+	fsys  fs.FS
+	opts  StaticOptions
+	etags sync.Map
+}
+
+type staticFile struct {
+	file    fs.File
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func (g *routeGroup) ServeStatic(prefix string, fsys fs.FS, opts StaticOptions) {
+	if opts.Index == "" {
+		opts.Index = "index.html"
+	}
+
+	files := &staticFiles{fsys: fsys, opts: opts}
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	if prefix != "" {
+		if err := g.router.handle(g, http.MethodGet, prefix, "Static", files.serve); err != nil {
+			g.router.report("%v", err)
+		}
+	}
+
+	if err := g.router.handle(g, http.MethodGet, prefix+"/*filepath", "Static", files.serve); err != nil {
+		g.router.report("%v", err)
+	}
+}
+
+func (s *staticFiles) serve(req *rest.Request) rest.Response {
+	name := strings.TrimPrefix(pathpkg.Clean("/"+req.PathParams["filepath"]), "/")
+	if name == "" {
+		name = "."
+	}
+
+	file, err := s.open(name)
+	if errors.Is(err, fs.ErrNotExist) && s.opts.SPAFallback && pathpkg.Ext(name) == "" {
+		file, err = s.open(".")
+	}
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return rest.NotFound()
+	}
+
+	if err != nil {
+		return rest.Problem(http.StatusInternalServerError, "error opening file")
+	}
+
+	return s.respond(req, file)
+}
+
+// open resolves name to a regular file, descending into the index file of
+// directories.
+func (s *staticFiles) open(name string) (*staticFile, error) {
+	file, err := s.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if info.IsDir() {
+		file.Close()
+		index := pathpkg.Join(name, s.opts.Index)
+		if index == name {
+			return nil, fs.ErrNotExist
+		}
+		return s.open(index)
+	}
+
+	return &staticFile{
+		file:    file,
+		name:    name,
+		size:    info.Size(),
+		modTime: info.ModTime(),
+	}, nil
+}
+
+func (s *staticFiles) respond(req *rest.Request, file *staticFile) rest.Response {
+	headers := http.Header(req.Headers)
+	response := rest.Response{Status: 200, Headers: make(http.Header)}
+	contentType := mime.TypeByExtension(pathpkg.Ext(file.name))
+
+	if s.opts.Precompressed {
+		response.Headers.Set("Vary", "Accept-Encoding")
+		if acceptsEncoding(headers, "gzip") {
+			if gz, err := s.open(file.name + ".gz"); err == nil {
+				file.file.Close()
+				file = gz
+				response.Headers.Set("Content-Encoding", "gzip")
+			}
+		}
+	}
+
+	etag, err := s.etag(file)
+	if err != nil {
+		file.file.Close()
+		return rest.Problem(http.StatusInternalServerError, "error reading file")
+	}
+
+	response.Headers.Set("ETag", etag)
+	response.Headers.Set("Accept-Ranges", "bytes")
+	if !file.modTime.IsZero() {
+		response.Headers.Set("Last-Modified", file.modTime.UTC().Format(http.TimeFormat))
+	}
+
+	if s.opts.MaxAge > 0 {
+		response.Headers.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(s.opts.MaxAge.Seconds())))
+	}
+
+	if rest.NotModified(headers, etag, file.modTime) {
+		file.file.Close()
+		response.Status = http.StatusNotModified
+		return response
+	}
+
+	content, err := seekable(file.file)
+	if err != nil {
+		file.file.Close()
+		return rest.Problem(http.StatusInternalServerError, "error reading file")
+	}
+
+	if contentType == "" {
+		var sniff [512]byte
+		n, _ := io.ReadFull(content, sniff[:])
+		contentType = http.DetectContentType(sniff[:n])
+		if _, err := content.Seek(0, io.SeekStart); err != nil {
+			file.file.Close()
+			return rest.Problem(http.StatusInternalServerError, "error reading file")
+		}
+	}
+	response.Headers.Set("Content-Type", contentType)
+
+	start, length, status := parseRange(headers, etag, file.modTime, file.size)
+	switch status {
+	case http.StatusRequestedRangeNotSatisfiable:
+		file.file.Close()
+		response.Status = status
+		response.Headers.Set("Content-Range", fmt.Sprintf("bytes */%d", file.size))
+		return response
+	case http.StatusPartialContent:
+		if _, err := content.Seek(start, io.SeekStart); err != nil {
+			file.file.Close()
+			return rest.Problem(http.StatusInternalServerError, "error reading file")
+		}
+		response.Status = status
+		response.Headers.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, start+length-1, file.size))
+	}
+
+	response.Headers.Set("Content-Length", strconv.FormatInt(length, 10))
+	response.Body = readCloser{Reader: io.LimitReader(content, length), Closer: file.file}
+	return response
+}
+
+// etag returns a strong validator derived from the file content. Hashes are
+// cached per file, keyed by size and modification time so that changes on
+// disk-backed filesystems are picked up.
+func (s *staticFiles) etag(file *staticFile) (string, error) {
+	key := fmt.Sprintf("%s:%d:%d", file.name, file.size, file.modTime.UnixNano())
+	if etag, ok := s.etags.Load(key); ok {
+		return etag.(string), nil
+	}
+
+	f, err := s.fsys.Open(file.name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+	s.etags.Store(key, etag)
+	return etag, nil
+}
+
+func seekable(file fs.File) (io.ReadSeeker, error) {
+	if rs, ok := file.(io.ReadSeeker); ok {
+		return rs, nil
+	}
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(content), nil
+}
+
+// parseRange supports a single "bytes=" range. Multiple ranges and stale
+// If-Range validators fall back to the full content.
+func parseRange(headers http.Header, etag string, modTime time.Time, size int64) (int64, int64, int) {
+	spec, ok := strings.CutPrefix(headers.Get("Range"), "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, size, http.StatusOK
+	}
+
+	if size == 0 {
+		return 0, 0, http.StatusRequestedRangeNotSatisfiable
+	}
+
+	if ifRange := headers.Get("If-Range"); ifRange != "" {
+		if t, err := http.ParseTime(ifRange); err == nil {
+			if modTime.IsZero() || modTime.Truncate(time.Second).After(t) {
+				return 0, size, http.StatusOK
+			}
+		} else if !rest.ETagMatches(ifRange, etag, false) {
+			return 0, size, http.StatusOK
+		}
+	}
+
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, size, http.StatusOK
+	}
+
+	var start, end int64
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, http.StatusRequestedRangeNotSatisfiable
+		}
+		start, end = max(size-n, 0), size-1
+	} else {
+		var err error
+		start, err = strconv.ParseInt(first, 10, 64)
+		if err != nil || start >= size {
+			return 0, 0, http.StatusRequestedRangeNotSatisfiable
+		}
+
+		end = size - 1
+		if last != "" {
+			end, err = strconv.ParseInt(last, 10, 64)
+			if err != nil || end < start {
+				return 0, 0, http.StatusRequestedRangeNotSatisfiable
+			}
+			end = min(end, size-1)
+		}
+	}
+
+	return start, end - start + 1, http.StatusPartialContent
+}
+
+func acceptsEncoding(headers http.Header, encoding string) bool {
+	for _, value := range strings.Split(headers.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(value), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+			continue
+		}
+		return strings.ReplaceAll(params, " ", "") != "q=0"
+	}
+	return false
+}
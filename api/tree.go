@@ -0,0 +1,270 @@
+package api
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type nodeKind uint8
+
+const (
+	staticNode nodeKind = iota
+	paramNode
+	catchAllNode
+)
+
+// node is a vertex of a compressed radix tree. Static nodes hold a shared
+// byte prefix of their descendants, param nodes match one path segment and
+// catch-all nodes match the rest of the path. Parameter names are kept on
+// the route, not on the node, so routes that only differ by parameter name
+// share the same branch.
+type node struct {
+	kind       nodeKind
+	label      string
+	constraint *constraint
+	indices    string
+	children   []*node
+	params     []*node
+	catchAll   *node
+	route      *route
+}
+
+type paramValue struct {
+	raw   string
+	value interface{}
+}
+
+func newTree() *node {
+	return &node{kind: staticNode}
+}
+
+func (n *node) insert(path string, rt *route) error {
+	if path == "" {
+		n.route = rt
+		return nil
+	}
+
+	switch path[0] {
+	case ':':
+		end, err := paramEnd(path)
+		if err != nil {
+			return err
+		}
+
+		_, expr := splitParam(path[1:end])
+		child, err := n.paramChild(expr)
+		if err != nil {
+			return err
+		}
+		return child.insert(path[end:], rt)
+	case '*':
+		if len(path) == 1 {
+			return fmt.Errorf("empty catch-all name in %q", rt.PathPattern)
+		}
+
+		if strings.IndexByte(path, '/') >= 0 {
+			return fmt.Errorf("catch-all parameter must be the last segment in %q", rt.PathPattern)
+		}
+
+		if n.catchAll == nil {
+			n.catchAll = &node{kind: catchAllNode}
+		}
+		n.catchAll.route = rt
+		return nil
+	}
+
+	end := nextParam(path)
+	static := path[:end]
+	if strings.ContainsAny(static, "{}") {
+		return fmt.Errorf("unexpected constraint outside a parameter in %q", rt.PathPattern)
+	}
+
+	if i := strings.IndexByte(n.indices, static[0]); i >= 0 {
+		child := n.children[i]
+		l := commonPrefix(static, child.label)
+		if l < len(child.label) {
+			child.split(l)
+		}
+		return child.insert(path[l:], rt)
+	}
+
+	child := &node{kind: staticNode, label: static}
+	n.indices += string(static[0])
+	n.children = append(n.children, child)
+	return child.insert(path[end:], rt)
+}
+
+// paramChild returns the param child for the given constraint expression,
+// creating it if needed. Constrained params are kept ahead of the
+// unconstrained one so that they are tried first while matching.
+func (n *node) paramChild(expr string) (*node, error) {
+	for _, child := range n.params {
+		if child.constraint.expr == expr {
+			return child, nil
+		}
+	}
+
+	c, err := compileConstraint(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	child := &node{kind: paramNode, constraint: c}
+	if last := len(n.params) - 1; expr != "" && last >= 0 && n.params[last].constraint.expr == "" {
+		n.params = slices.Insert(n.params, last, child)
+	} else {
+		n.params = append(n.params, child)
+	}
+
+	return child, nil
+}
+
+func (n *node) split(at int) {
+	tail := &node{
+		kind:     staticNode,
+		label:    n.label[at:],
+		indices:  n.indices,
+		children: n.children,
+		params:   n.params,
+		catchAll: n.catchAll,
+		route:    n.route,
+	}
+
+	n.label = n.label[:at]
+	n.indices = string(tail.label[0])
+	n.children = []*node{tail}
+	n.params = nil
+	n.catchAll = nil
+	n.route = nil
+}
+
+// match walks the subtree against the unconsumed part of path. Static
+// children take precedence over constrained parameters, which take
+// precedence over plain parameters and finally catch-alls, which also match
+// an empty remainder so "/files/*path" serves "/files/". When a branch
+// dead-ends the walk backtracks and tries the next candidate.
+func (n *node) match(path string, values []paramValue) (*route, []paramValue) {
+	switch n.kind {
+	case staticNode:
+		if !strings.HasPrefix(path, n.label) {
+			return nil, values
+		}
+		path = path[len(n.label):]
+	case paramNode:
+		end := segmentEnd(path)
+		if end == 0 {
+			return nil, values
+		}
+
+		value, ok := n.constraint.parse(path[:end])
+		if !ok {
+			return nil, values
+		}
+		values = append(values, paramValue{raw: path[:end], value: value})
+		path = path[end:]
+	case catchAllNode:
+		return n.route, append(values, paramValue{raw: path, value: path})
+	}
+
+	if path == "" && n.route != nil {
+		return n.route, values
+	}
+
+	if path != "" {
+		if i := strings.IndexByte(n.indices, path[0]); i >= 0 {
+			if rt, v := n.children[i].match(path, values); rt != nil {
+				return rt, v
+			}
+		}
+
+		for _, param := range n.params {
+			if rt, v := param.match(path, values); rt != nil {
+				return rt, v
+			}
+		}
+	}
+
+	if n.catchAll != nil {
+		return n.catchAll.match(path, values)
+	}
+
+	return nil, values
+}
+
+func segmentEnd(path string) int {
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		return i
+	}
+	return len(path)
+}
+
+// paramEnd returns the end of the ":name{constraint}" token at the start
+// of path, honoring nested braces inside the constraint.
+func paramEnd(path string) (int, error) {
+	depth := 0
+	for i := 1; i < len(path); i++ {
+		switch path[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth < 0 {
+				return 0, fmt.Errorf("unbalanced constraint braces in %q", path)
+			}
+		case '/':
+			if depth == 0 {
+				return checkParam(path, i)
+			}
+			return 0, fmt.Errorf("constraint cannot contain '/' in %q", path)
+		}
+	}
+
+	if depth != 0 {
+		return 0, fmt.Errorf("unbalanced constraint braces in %q", path)
+	}
+	return checkParam(path, len(path))
+}
+
+func checkParam(path string, end int) (int, error) {
+	name, _ := splitParam(path[1:end])
+	if name == "" {
+		return 0, fmt.Errorf("empty parameter name in %q", path)
+	}
+
+	if i := strings.IndexByte(path[:end], '{'); i >= 0 && path[end-1] != '}' {
+		return 0, fmt.Errorf("unexpected characters after constraint in %q", path)
+	}
+	return end, nil
+}
+
+// splitParam splits "id{int}" into its name and constraint expression.
+func splitParam(token string) (string, string) {
+	i := strings.IndexByte(token, '{')
+	if i < 0 {
+		return token, ""
+	}
+	return token[:i], strings.TrimSuffix(token[i+1:], "}")
+}
+
+func nextParam(path string) int {
+	for i := 1; i < len(path); i++ {
+		if path[i-1] == '/' && (path[i] == ':' || path[i] == '*') {
+			return i
+		}
+	}
+	return len(path)
+}
+
+func commonPrefix(a, b string) int {
+	max := len(a)
+	if len(b) < max {
+		max = len(b)
+	}
+
+	i := 0
+	for i < max && a[i] == b[i] {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,292 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ramoncl001/comet/rest"
+)
+
+func buildTree(t testing.TB, patterns ...string) *node {
+	t.Helper()
+
+	tree := newTree()
+	for _, pattern := range patterns {
+		rt := &route{Method: "GET", PathPattern: pattern, ParamNames: getParamNames(pattern)}
+		if err := tree.insert(pattern, rt); err != nil {
+			t.Fatalf("insert %q: %v", pattern, err)
+		}
+	}
+	return tree
+}
+
+func TestTreeMatch(t *testing.T) {
+	tree := buildTree(t,
+		"/users",
+		"/users/me",
+		"/users/:id{int}",
+		"/users/:name",
+		"/users/:id{int}/orders/:orderId",
+		"/orders/:code{[A-Z]{3}}",
+		"/files/*path",
+		"/files/static/logo",
+		"/search",
+	)
+
+	tests := []struct {
+		path    string
+		pattern string
+		values  []string
+	}{
+		{"/users", "/users", nil},
+		{"/users/me", "/users/me", nil},
+		{"/users/42", "/users/:id{int}", []string{"42"}},
+		{"/users/bob", "/users/:name", []string{"bob"}},
+		{"/users/42/orders/7", "/users/:id{int}/orders/:orderId", []string{"42", "7"}},
+		{"/orders/ABC", "/orders/:code{[A-Z]{3}}", []string{"ABC"}},
+		{"/orders/abc", "", nil},
+		{"/files/static/logo", "/files/static/logo", nil},
+		{"/files/static/logo.png", "/files/*path", []string{"static/logo.png"}},
+		{"/files/a/b/c", "/files/*path", []string{"a/b/c"}},
+		{"/files/", "/files/*path", []string{""}},
+		{"/search", "/search", nil},
+		{"/searches", "", nil},
+		{"/users/bob/orders/7", "", nil},
+		{"/unknown", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rt, values := tree.match(tt.path, nil)
+			if tt.pattern == "" {
+				if rt != nil {
+					t.Fatalf("expected no match, got %q", rt.PathPattern)
+				}
+				return
+			}
+
+			if rt == nil {
+				t.Fatalf("expected %q, got no match", tt.pattern)
+			}
+
+			if rt.PathPattern != tt.pattern {
+				t.Fatalf("expected %q, got %q", tt.pattern, rt.PathPattern)
+			}
+
+			if len(values) != len(tt.values) {
+				t.Fatalf("expected values %v, got %v", tt.values, values)
+			}
+
+			for i, v := range values {
+				if v.raw != tt.values[i] {
+					t.Fatalf("expected values %v, got %v", tt.values, values)
+				}
+			}
+		})
+	}
+}
+
+func TestTreeConstraintValues(t *testing.T) {
+	tree := buildTree(t, "/items/:id{int}", "/flags/:on{bool}")
+
+	_, values := tree.match("/items/15", nil)
+	if v, ok := values[0].value.(int); !ok || v != 15 {
+		t.Fatalf("expected int 15, got %#v", values[0].value)
+	}
+
+	_, values = tree.match("/flags/true", nil)
+	if v, ok := values[0].value.(bool); !ok || !v {
+		t.Fatalf("expected bool true, got %#v", values[0].value)
+	}
+}
+
+func TestTreeInsertErrors(t *testing.T) {
+	for _, pattern := range []string{
+		"/files/*",
+		"/files/*path/more",
+		"/users/:",
+		"/users/:id{int",
+		"/users/:id{[0-9}",
+		"/users/:id{int}x",
+	} {
+		tree := newTree()
+		if err := tree.insert(pattern, &route{PathPattern: pattern}); err == nil {
+			t.Errorf("expected error inserting %q", pattern)
+		}
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	for _, template := range []string{
+		"/users",
+		"/users/:id",
+		"/users/:id{int}/orders",
+		"/orders/:code{[A-Z]{3}}",
+		"/files/*path",
+	} {
+		if err := validateTemplate(template); err != nil {
+			t.Errorf("unexpected error for %q: %v", template, err)
+		}
+	}
+
+	for _, template := range []string{
+		"/foo:bar",
+		"/foo/bar:id",
+		"/users/:",
+		"/users/:id{int",
+		"/users/{id}",
+		"/users/id}",
+		"/files/*",
+		"/files/*path/more",
+		"/files/a*b",
+	} {
+		if err := validateTemplate(template); err == nil {
+			t.Errorf("expected error for %q", template)
+		}
+	}
+}
+
+func TestRouteAmbiguity(t *testing.T) {
+	tests := []struct {
+		a, b      string
+		ambiguous bool
+	}{
+		{"/users/:id", "/users/:name", true},
+		{"/users/:id{int}", "/users/:id{int}", true},
+		{"/users/:id{int}", "/users/:id{\\d+}", true},
+		{"/users/:id{int}", "/users/:code{[0-9]{3}}", true},
+		{"/users/:id{alpha}", "/users/:id{[a-z]+}", true},
+		{"/users/:id{int}", "/users/:flag{bool}", true},
+		{"/users/:id{int}", "/users/:name", false},
+		{"/users/:id{int}", "/users/:name{alpha}", false},
+		{"/users/:id{uuid}", "/users/:code{[A-Z]{3}}", false},
+		{"/users/:id{int}/a/:x", "/users/:name{alpha}/a/:y", false},
+		{"/files/*path", "/files/*rest", true},
+	}
+
+	for _, tt := range tests {
+		r := newRouter()
+		group := newRouteGroup(r, nil, "")
+		if err := r.handle(group, "GET", tt.a, "A", nil); err != nil {
+			t.Fatalf("%s: %v", tt.a, err)
+		}
+
+		err := r.handle(group, "GET", tt.b, "B", nil)
+		if (err != nil) != tt.ambiguous {
+			t.Errorf("%s and %s: expected ambiguous %v, got %v", tt.a, tt.b, tt.ambiguous, err)
+		}
+	}
+}
+
+func TestGroupedRouteParams(t *testing.T) {
+	r := newRouter()
+	group := newRouteGroup(r, newRouteGroup(r, nil, ""), "/t/:tenant")
+	handler := func(req *rest.Request) rest.Response {
+		return rest.Ok(req.PathParams)
+	}
+
+	if err := r.handle(group, "GET", "/assets/*filepath", "Static", handler); err != nil {
+		t.Fatal(err)
+	}
+
+	rt, params, _ := r.lookup("GET", "/t/acme/assets/css/app.css")
+	if rt == nil {
+		t.Fatal("expected a match")
+	}
+
+	if params["tenant"] != "acme" || params["filepath"] != "css/app.css" {
+		t.Fatalf("unexpected params %v", params)
+	}
+}
+
+// linearRoute and linearMatch reproduce the router that preceded the tree:
+// every route is compared segment by segment against the request path.
+type linearRoute struct {
+	pattern string
+	parts   []string
+}
+
+func linearMatch(routes []linearRoute, path string) (*linearRoute, map[string]string) {
+	parts := strings.Split(path, "/")
+	for i := range routes {
+		rt := &routes[i]
+		if len(parts) != len(rt.parts) {
+			continue
+		}
+
+		params := make(map[string]string)
+		matched := true
+		for j, part := range rt.parts {
+			if strings.HasPrefix(part, ":") {
+				params[part[1:]] = parts[j]
+			} else if part != parts[j] {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return rt, params
+		}
+	}
+	return nil, nil
+}
+
+func benchmarkPatterns(n int) []string {
+	patterns := make([]string, 0, n*4)
+	for i := 0; i < n; i++ {
+		patterns = append(patterns,
+			fmt.Sprintf("/resource%d", i),
+			fmt.Sprintf("/resource%d/:id", i),
+			fmt.Sprintf("/resource%d/:id/children", i),
+			fmt.Sprintf("/resource%d/:id/children/:childId", i),
+		)
+	}
+	return patterns
+}
+
+func benchmarkRouters(b *testing.B, n int) {
+	patterns := benchmarkPatterns(n)
+	path := fmt.Sprintf("/resource%d/42/children/7", n-1)
+
+	b.Run("tree", func(b *testing.B) {
+		tree := buildTree(b, patterns...)
+		values := make([]paramValue, 0, 4)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if rt, _ := tree.match(path, values[:0]); rt == nil {
+				b.Fatal("no match")
+			}
+		}
+	})
+
+	b.Run("linear", func(b *testing.B) {
+		routes := make([]linearRoute, len(patterns))
+		for i, pattern := range patterns {
+			routes[i] = linearRoute{pattern: pattern, parts: strings.Split(pattern, "/")}
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if rt, _ := linearMatch(routes, path); rt == nil {
+				b.Fatal("no match")
+			}
+		}
+	})
+}
+
+func BenchmarkMatch10(b *testing.B)   { benchmarkRouters(b, 10) }
+func BenchmarkMatch100(b *testing.B)  { benchmarkRouters(b, 100) }
+func BenchmarkMatch1000(b *testing.B) { benchmarkRouters(b, 1000) }
+
+func BenchmarkMatchStatic(b *testing.B) {
+	tree := buildTree(b, benchmarkPatterns(100)...)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if rt, _ := tree.match("/resource99", nil); rt == nil {
+			b.Fatal("no match")
+		}
+	}
+}
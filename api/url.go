@@ -0,0 +1,91 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// names lists the names URLFor accepts for the route, the most specific
+// last. Controller routes are also named after their group prefix, e.g.
+// "UsersController.GetByID@/v1" or "@/" at the root, to tell apart a
+// controller mapped in several groups.
+func (rt *route) names() []string {
+	if rt.controller == nil {
+		return []string{rt.Handler.Name}
+	}
+
+	names := []string{
+		rt.controller.name + "." + rt.Handler.Name,
+		rt.controller.qualified + "." + rt.Handler.Name,
+	}
+
+	prefix := rt.group.prefix
+	if prefix == "" {
+		prefix = "/"
+	}
+	return append(names, names[0]+"@"+prefix, names[1]+"@"+prefix)
+}
+
+func (r *router) URLFor(name string, params map[string]string, query url.Values) (string, error) {
+	routes := r.names[name]
+	if len(routes) == 0 {
+		return "", fmt.Errorf("no route named %s", name)
+	}
+
+	if len(routes) > 1 {
+		candidates := make([]string, 0, len(routes))
+		for _, rt := range routes {
+			names := rt.names()
+			candidates = append(candidates, names[len(names)-1])
+		}
+		return "", fmt.Errorf("route name %s is ambiguous, use one of: %s", name, strings.Join(candidates, ", "))
+	}
+
+	path, err := expandTemplate(routes[0].PathPattern, params)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return path, nil
+}
+
+func expandTemplate(template string, params map[string]string) (string, error) {
+	parts := strings.Split(template, "/")
+	for i, part := range parts {
+		switch {
+		case strings.HasPrefix(part, ":"):
+			name, expr := splitParam(part[1:])
+			value, ok := params[name]
+			if !ok || value == "" {
+				return "", fmt.Errorf("missing path parameter %q", name)
+			}
+
+			c, err := compileConstraint(expr)
+			if err != nil {
+				return "", err
+			}
+
+			if _, ok := c.parse(value); !ok {
+				return "", fmt.Errorf("path parameter %q does not satisfy constraint %q", name, expr)
+			}
+			parts[i] = url.PathEscape(value)
+		case strings.HasPrefix(part, "*"):
+			value, ok := params[part[1:]]
+			if !ok {
+				return "", fmt.Errorf("missing path parameter %q", part[1:])
+			}
+
+			segments := strings.Split(value, "/")
+			for j, segment := range segments {
+				segments[j] = url.PathEscape(segment)
+			}
+			parts[i] = strings.Join(segments, "/")
+		}
+	}
+
+	return strings.Join(parts, "/"), nil
+}
@@ -6,18 +6,25 @@ package comet
 
 import (
 	"context"
+	"io"
+	"mime/multipart"
 
 	"github.com/ramoncl001/comet/api"
 	"github.com/ramoncl001/comet/ioc"
 	"github.com/ramoncl001/comet/log"
 	"github.com/ramoncl001/comet/middleware"
 	"github.com/ramoncl001/comet/rest"
+	"github.com/ramoncl001/comet/serialization"
 )
 
 // ApiServer represents the main API server instance.
 // Handles HTTP requests, routing, middleware, and application lifecycle.
 type ApiServer api.ApiServer
 
+// RouteGroup prefixes a set of controllers and attaches shared middleware
+// and policies to them, e.g. to version an API under "/api/v1".
+type RouteGroup api.RouteGroup
+
 // ControllerBase provides the foundation for all API controllers.
 // Offers helper methods for request handling and response generation.
 type ControllerBase rest.ControllerBase
@@ -34,6 +41,36 @@ type Response rest.Response
 // The fundamental building block for defining API endpoints and handlers.
 type RequestHandler = func(r *Request) Response
 
+// URLGenerator builds URLs from registered routes. It is available in the
+// IoC container, so controllers can receive it through their constructor.
+type URLGenerator = rest.URLGenerator
+
+// FormFile is an uploaded multipart file. Use it for request model fields
+// tagged with `form` to bind uploads.
+type FormFile = rest.FormFile
+
+// Serializer converts values to and from one wire format. Responses and
+// request bodies pick one by media type through content negotiation.
+type Serializer = serialization.Serializer
+
+// JSONOptions configures field naming, null handling, time and number
+// formats and the encoder used for JSON requests and responses.
+type JSONOptions = serialization.JSONOptions
+
+// Event is a single Server-Sent Event written to an EventStream.
+type Event = rest.Event
+
+// EventStream sends Server-Sent Events to the client of an SSE response.
+type EventStream = rest.EventStream
+
+// WebSocket is the connection handed to controller actions prefixed with
+// Ws, with JSON helpers, pings and close codes.
+type WebSocket = rest.WebSocket
+
+// ProblemDetails is the RFC 7807 error body used for every built-in
+// failure. Actions may return it as an error.
+type ProblemDetails = rest.ProblemDetails
+
 // Middleware is a function that intercepts and processes HTTP requests
 // before they reach the main handler, enabling cross-cutting concerns.
 type Middleware = func(next RequestHandler) RequestHandler
@@ -126,6 +163,30 @@ func LoggerFromContext(ctx context.Context) log.Logger {
 	return log.FromContext(ctx)
 }
 
+// Bind populates a T from the request body, path, query, form and headers
+// using struct tags, reporting conversion failures per field.
+func Bind[T any](req *Request) (T, error) {
+	return rest.Bind[T]((*rest.Request)(req))
+}
+
+// RegisterSerializer adds or replaces the serializer used for a media type
+// when negotiating responses and binding request bodies.
+func RegisterSerializer(mediaType string, serializer Serializer) {
+	serialization.Register(mediaType, serializer)
+}
+
+// SSE answers with a Server-Sent Events stream fed by handler, which should
+// return once ctx is done because the client went away.
+func SSE(req *Request, handler func(ctx context.Context, stream *EventStream) error) Response {
+	return Response(rest.SSE((*rest.Request)(req), handler))
+}
+
+// RegisterError maps errors matching target, as per errors.Is, to a problem
+// response with the given status when actions return them.
+func RegisterError(target error, status int) {
+	rest.RegisterError(target, status)
+}
+
 // RequestLogging middleware automatically logs incoming HTTP requests
 // and responses with relevant timing and metadata information.
 var RequestLogging = middleware.RequestLogging
@@ -134,6 +195,104 @@ var RequestLogging = middleware.RequestLogging
 // preventing server crashes and providing structured error responses.
 var Recover = middleware.Recover
 
+// BodyLimit returns a middleware that rejects request bodies larger than
+// the given number of bytes with 413 Payload Too Large.
+var BodyLimit = middleware.BodyLimit
+
+// Multipart returns a middleware that sets the memory and size limits used
+// to parse form uploads, including when Bind parses them.
+var Multipart = middleware.Multipart
+
+// WebSocketConfig returns a middleware that sets the read limit, pings,
+// timeouts and origin check used by the wrapped websocket actions.
+var WebSocketConfig = middleware.WebSocketConfig
+
 // RequestID middleware automatically generates and assigns unique identifiers
 // to each incoming request for improved tracing and debugging capabilities.
 var RequestID = middleware.RequestID
+
+// ETag returns a middleware that tags GET responses, answers 304 Not
+// Modified and rejects stale PUT, PATCH and DELETE requests with 412.
+var ETag = middleware.ETag
+
+// Compress returns a middleware that gzip or deflate encodes responses,
+// including streams and Server-Sent Events, as negotiated by the client.
+var Compress = middleware.Compress
+
+// Context returns the request context, carrying the request scope, the
+// logger and values set by middlewares.
+func (r *Request) Context() context.Context {
+	return (*rest.Request)(r).Context()
+}
+
+// ReadBody reads the whole request body on first use and keeps it in Body.
+// It fails with 413 Payload Too Large past the configured body limit.
+func (r *Request) ReadBody() ([]byte, error) {
+	return (*rest.Request)(r).ReadBody()
+}
+
+// BodyReader streams the request body without buffering it. Once streamed,
+// the body cannot be read again through ReadBody.
+func (r *Request) BodyReader() io.Reader {
+	return (*rest.Request)(r).BodyReader()
+}
+
+// LimitBody caps the number of bytes that can be read from the body,
+// replacing any previous limit.
+func (r *Request) LimitBody(limit int64) {
+	(*rest.Request)(r).LimitBody(limit)
+}
+
+// MultipartForm parses a multipart form with the options set for the
+// route, or the default limits.
+func (r *Request) MultipartForm() (*rest.Form, error) {
+	return (*rest.Request)(r).MultipartForm()
+}
+
+// FormValue returns the first value for name in the form body, falling back
+// to the query string.
+func (r *Request) FormValue(name string) string {
+	return (*rest.Request)(r).FormValue(name)
+}
+
+// File opens the first file uploaded under a multipart form field, failing
+// with http.ErrMissingFile when there is none. The caller must close it.
+func (r *Request) File(name string) (multipart.File, *FormFile, error) {
+	return (*rest.Request)(r).File(name)
+}
+
+// UserID returns the id of the authenticated user, or an empty string for
+// anonymous requests.
+func (r *Request) UserID() string {
+	return (*rest.Request)(r).UserID()
+}
+
+// Claims returns the claims of the authenticated user, set by the
+// authentication middleware.
+func (r *Request) Claims() rest.Claims {
+	return (*rest.Request)(r).Claims()
+}
+
+// RequestID returns the identifier assigned by the RequestID middleware,
+// or an empty string when it is not installed.
+func (r *Request) RequestID() string {
+	return (*rest.Request)(r).RequestID()
+}
+
+// TraceID returns the trace identifier attached to the request logger and
+// to problem details.
+func (r *Request) TraceID() string {
+	return (*rest.Request)(r).TraceID()
+}
+
+// RouteTemplate returns the template of the matched route, such as
+// "/users/:id", or an empty string before routing.
+func (r *Request) RouteTemplate() string {
+	return (*rest.Request)(r).RouteTemplate()
+}
+
+// LastEventID returns the Last-Event-ID sent by a reconnecting
+// Server-Sent Events client.
+func (r *Request) LastEventID() string {
+	return (*rest.Request)(r).LastEventID()
+}
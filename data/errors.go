@@ -0,0 +1,15 @@
+package data
+
+import (
+	"net/http"
+
+	"github.com/ramoncl001/comet/rest"
+	"gorm.io/gorm"
+)
+
+func init() {
+	rest.RegisterError(gorm.ErrRecordNotFound, http.StatusNotFound)
+	rest.RegisterError(gorm.ErrDuplicatedKey, http.StatusConflict)
+	rest.RegisterError(gorm.ErrForeignKeyViolated, http.StatusConflict)
+	rest.RegisterError(gorm.ErrCheckConstraintViolated, http.StatusUnprocessableEntity)
+}
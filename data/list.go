@@ -0,0 +1,88 @@
+package data
+
+import (
+	"strings"
+
+	"github.com/ramoncl001/comet/rest"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Database is accepted by the list helpers, so they can be called with a
+// *DatabaseContext as well as a *gorm.DB.
+type Database interface {
+	*gorm.DB | *DatabaseContext
+}
+
+func gormDB[D Database](db D) *gorm.DB {
+	if ctx, ok := interface{}(db).(*DatabaseContext); ok {
+		return ctx.DB
+	}
+	return interface{}(db).(*gorm.DB)
+}
+
+// likeEscaper escapes the wildcards of LIKE so filter values match
+// literally, with backslash as the escape character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// Filter adds the filters of query to db. Columns come from the
+// whitelist in rest.ListOptions, and values are always bound as
+// parameters. Like filters match the value anywhere in the column.
+func Filter[D Database](database D, query rest.ListQuery) *gorm.DB {
+	db := gormDB(database)
+	for _, filter := range query.Filters {
+		column := clause.Column{Name: filter.Column}
+		switch filter.Op {
+		case rest.FilterNe:
+			db = db.Where(clause.Neq{Column: column, Value: filter.Value})
+		case rest.FilterGt:
+			db = db.Where(clause.Gt{Column: column, Value: filter.Value})
+		case rest.FilterGte:
+			db = db.Where(clause.Gte{Column: column, Value: filter.Value})
+		case rest.FilterLt:
+			db = db.Where(clause.Lt{Column: column, Value: filter.Value})
+		case rest.FilterLte:
+			db = db.Where(clause.Lte{Column: column, Value: filter.Value})
+		case rest.FilterLike:
+			db = db.Where(clause.Expr{
+				SQL:  "? LIKE ? ESCAPE ?",
+				Vars: []interface{}{column, "%" + likeEscaper.Replace(filter.Value) + "%", `\`},
+			})
+		case rest.FilterIn:
+			values := make([]interface{}, 0)
+			for _, value := range filter.Values() {
+				values = append(values, value)
+			}
+			db = db.Where(clause.IN{Column: column, Values: values})
+		default:
+			db = db.Where(clause.Eq{Column: column, Value: filter.Value})
+		}
+	}
+	return db
+}
+
+// Paginate adds the sorting, offset and limit of query to db.
+func Paginate[D Database](database D, query rest.ListQuery) *gorm.DB {
+	db := gormDB(database)
+	for _, sort := range query.Sort {
+		db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: sort.Column}, Desc: sort.Desc})
+	}
+	return db.Offset(query.Offset()).Limit(query.PageSize)
+}
+
+// FindPage runs query against db and returns one page of T along with the
+// total number of matching rows, ready for rest.NewPage.
+func FindPage[T any, D Database](db D, query rest.ListQuery) ([]T, int64, error) {
+	filtered := Filter(gormDB(db).Model(new(T)), query).Session(&gorm.Session{})
+
+	var total int64
+	if err := filtered.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var items []T
+	if err := Paginate(filtered, query).Find(&items).Error; err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
@@ -60,3 +60,27 @@ func resolveKeyed(ctx context.Context, t reflect.Type, key interface{}) (interfa
 }
 
 var mu sync.RWMutex
+
+func Resolve(ctx context.Context, t reflect.Type) (interface{}, error) {
+	result, err := resolve(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		return nil, errDependencyNotFound
+	}
+
+	return result, nil
+}
+
+// Registered reports whether a service of type t is registered without a key.
+func Registered(t reflect.Type) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	_, singleton := singletonServices[t][0]
+	_, transient := transientServices[t][0]
+	_, scoped := scopedServices[t][0]
+	return singleton || transient || scoped
+}
@@ -3,8 +3,27 @@ package ioc
 import (
 	"context"
 	"reflect"
+	"sync"
 )
 
+type scopeKey struct{}
+
+type scopedKey struct {
+	typ reflect.Type
+	key interface{}
+}
+
+type scope struct {
+	mu       sync.Mutex
+	services map[scopedKey]interface{}
+}
+
+// NewScope returns a context in which every scoped service is created once
+// and then reused, e.g. for the lifetime of a request or a connection.
+func NewScope(ctx context.Context) context.Context {
+	return context.WithValue(ctx, scopeKey{}, &scope{services: make(map[scopedKey]interface{})})
+}
+
 func RegisterScoped[T any](provider interface{}) {
 	tp := reflect.TypeOf((*T)(nil)).Elem()
 
@@ -76,6 +95,17 @@ func resolveScoped(ctx context.Context, t reflect.Type, key interface{}) (interf
 		return provider.value, nil
 	}
 
+	current, _ := ctx.Value(scopeKey{}).(*scope)
+	if current != nil {
+		current.mu.Lock()
+		instance, ok := current.services[scopedKey{typ: t, key: key}]
+		current.mu.Unlock()
+
+		if ok {
+			return instance, nil
+		}
+	}
+
 	args := make([]reflect.Value, tp.NumIn())
 	for i := 0; i < tp.NumIn(); i++ {
 		argType := tp.In(i)
@@ -87,7 +117,17 @@ func resolveScoped(ctx context.Context, t reflect.Type, key interface{}) (interf
 	}
 
 	result := reflect.ValueOf(provider.value).Call(args)
-	ctx = context.WithValue(ctx, t, result[0].Interface())
+	instance := result[0].Interface()
+
+	if current != nil {
+		current.mu.Lock()
+		defer current.mu.Unlock()
+
+		if existing, ok := current.services[scopedKey{typ: t, key: key}]; ok {
+			return existing, nil
+		}
+		current.services[scopedKey{typ: t, key: key}] = instance
+	}
 
-	return result[0].Interface(), nil
+	return instance, nil
 }
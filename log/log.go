@@ -1,9 +1,37 @@
 package log
 
+import "context"
+
+// Deprecated: TRACE_ID is the raw context key WithTraceID still writes, for
+// code reading it directly. Use TraceIDFromContext instead.
 const (
 	TRACE_ID string = "traceID"
 )
 
+type traceKey struct{}
+
+// WithTraceID stores id under a private key, and under TRACE_ID until that
+// key is removed.
+func WithTraceID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, TRACE_ID, id)
+	return context.WithValue(ctx, traceKey{}, id)
+}
+
+// TraceIDFromContext returns the trace id of the request being served, or
+// "" outside of a request.
+func TraceIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	if id, ok := ctx.Value(traceKey{}).(string); ok {
+		return id
+	}
+
+	id, _ := ctx.Value(TRACE_ID).(string)
+	return id
+}
+
 type Logger interface {
 	Info(message string, args ...interface{})
 	Debug(message string, args ...interface{})
@@ -21,25 +21,25 @@ func FromContext(ctx context.Context) Logger {
 }
 
 func (log *slogLogger) Debug(message string, args ...interface{}) {
-	traceID := log.ctx.Value(TRACE_ID)
+	traceID := TraceIDFromContext(log.ctx)
 	args = append(args, "trace_id", traceID)
 	log.logger.Log(log.ctx, slog.LevelDebug.Level(), message, args...)
 }
 
 func (log *slogLogger) Error(message string, args ...interface{}) {
-	traceID := log.ctx.Value(TRACE_ID)
+	traceID := TraceIDFromContext(log.ctx)
 	args = append(args, "trace_id", traceID)
 	log.logger.Log(log.ctx, slog.LevelError.Level(), message, args...)
 }
 
 func (log *slogLogger) Info(message string, args ...interface{}) {
-	traceID := log.ctx.Value(TRACE_ID)
+	traceID := TraceIDFromContext(log.ctx)
 	args = append(args, "trace_id", traceID)
 	log.logger.Log(log.ctx, slog.LevelInfo.Level(), message, args...)
 }
 
 func (log *slogLogger) Warn(message string, args ...interface{}) {
-	traceID := log.ctx.Value(TRACE_ID)
+	traceID := TraceIDFromContext(log.ctx)
 	args = append(args, "trace_id", traceID)
 	log.logger.Log(log.ctx, slog.LevelWarn.Level(), message, args...)
 }
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"github.com/ramoncl001/comet/rest"
+)
+
+// BodyLimit caps request bodies at limit bytes. It can be installed
+// globally with UseMiddleware or on single actions. Each BodyLimit replaces
+// the limit set by the ones wrapped around it, so the innermost limit wins,
+// e.g. a route limit raises or lowers a global one. The limit is enforced
+// when the body is read: a larger Content-Length is rejected before
+// reading, a chunked body once it crosses the limit, and binding reports
+// both as 413 Payload Too Large.
+func BodyLimit(limit int64) Middleware {
+	return func(next rest.RequestHandler) rest.RequestHandler {
+		return func(req *rest.Request) rest.Response {
+			req.LimitBody(limit)
+			return next(req)
+		}
+	}
+}
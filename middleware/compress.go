@@ -0,0 +1,419 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ramoncl001/comet/rest"
+)
+
+// Compressor is a compressing writer. Flush must push buffered data to the
+// underlying writer so streamed responses reach the client.
+type Compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// Encoder creates a Compressor writing to w. The level is passed as
+// configured in CompressOptions and may be ignored.
+type Encoder func(w io.Writer, level int) (Compressor, error)
+
+type encoding struct {
+	name    string
+	encoder Encoder
+}
+
+var (
+	encodersMu sync.RWMutex
+	encoders   []encoding
+)
+
+func init() {
+	RegisterEncoder("gzip", func(w io.Writer, level int) (Compressor, error) {
+		return gzip.NewWriterLevel(w, level)
+	})
+	RegisterEncoder("deflate", func(w io.Writer, level int) (Compressor, error) {
+		return flate.NewWriter(w, level)
+	})
+}
+
+// RegisterEncoder adds or replaces the encoder for a content coding, e.g.
+// "br" backed by a third-party brotli package. When the client accepts
+// several codings equally, the most recently registered one wins.
+func RegisterEncoder(name string, encoder Encoder) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+
+	name = strings.ToLower(name)
+	encoders = slices.DeleteFunc(encoders, func(e encoding) bool {
+		return e.name == name
+	})
+	encoders = append(encoders, encoding{name: name, encoder: encoder})
+}
+
+type CompressOptions struct {
+	Level int
+	// MinSize skips bodies smaller than this many bytes. Streamed bodies
+	// are only checked when they carry a Content-Length.
+	MinSize int
+	// ContentTypes lists the compressible media types. Entries ending in
+	// "/" match a type prefix, entries starting with "+" a suffix.
+	ContentTypes []string
+}
+
+var DefaultCompressOptions = CompressOptions{
+	Level:   gzip.DefaultCompression,
+	MinSize: 1024,
+	ContentTypes: []string{
+		"text/",
+		"+json",
+		"+xml",
+		"application/json",
+		"application/xml",
+		"application/javascript",
+		"application/x-ndjson",
+		"application/wasm",
+	},
+}
+
+// Compress encodes response bodies with the best coding accepted by the
+// client. Serialized and raw bodies are compressed in memory, streams and
+// Server-Sent Events as they are written, flushing with each event.
+// Strong tags of encoded responses get a "-<coding>" suffix; it is removed
+// from If-Match and If-None-Match before the request reaches inner handlers,
+// so they compare against the identity tags they produce.
+func Compress(opts CompressOptions) Middleware {
+	return func(next rest.RequestHandler) rest.RequestHandler {
+		return func(req *rest.Request) rest.Response {
+			req.Headers = trimEncodings(req.Headers)
+			response := next(req)
+
+			headers := http.Header(req.Headers)
+			if !bodyAllowed(req.Method, response.Status) || headers.Get("Upgrade") != "" ||
+				response.Headers.Get("Content-Encoding") != "" || response.Headers.Get("Content-Range") != "" {
+				return response
+			}
+
+			if _, ok := response.Data.(rest.ProblemDetails); ok {
+				return response
+			}
+
+			if response.Writer != nil {
+				response.Writer = compressWriter(response.Writer, headers, opts)
+				return response
+			}
+
+			if response.Raw == nil && response.Body == nil {
+				if response.Data == nil {
+					return response
+				}
+
+				encoded, err := encodeData(req, response)
+				if err != nil {
+					return response
+				}
+				response = encoded
+			}
+
+			if !compressible(response.Headers.Get("Content-Type"), opts) {
+				return response
+			}
+
+			response = response.WithHeader("Vary", varyEncoding(response.Headers))
+			name, encoder := negotiateEncoding(headers.Get("Accept-Encoding"))
+			if encoder == nil {
+				return response
+			}
+
+			if response.Raw != nil {
+				if len(response.Raw) < opts.MinSize {
+					return response
+				}
+
+				var buffer bytes.Buffer
+				compressor, err := encoder(&buffer, opts.Level)
+				if err != nil {
+					return response
+				}
+
+				if _, err := compressor.Write(response.Raw); err != nil {
+					compressor.Close()
+					return response
+				}
+
+				if err := compressor.Close(); err != nil {
+					return response
+				}
+
+				response.Raw = buffer.Bytes()
+				return encodedResponse(response, name)
+			}
+
+			length, err := strconv.Atoi(response.Headers.Get("Content-Length"))
+			if err == nil && length < opts.MinSize {
+				return response
+			}
+
+			body := response.Body
+			response.Writer = func(w http.ResponseWriter) error {
+				compressor, err := encoder(w, opts.Level)
+				if err != nil {
+					return err
+				}
+
+				w.WriteHeader(response.Status)
+				if _, err := io.Copy(compressor, body); err != nil {
+					compressor.Close()
+					return err
+				}
+				return compressor.Close()
+			}
+			return encodedResponse(response, name)
+		}
+	}
+}
+
+// encodedResponse labels a compressed response. Its length and byte ranges
+// no longer apply, and strong tags must differ from the identity coding.
+func encodedResponse(response rest.Response, name string) rest.Response {
+	response.Headers = response.Headers.Clone()
+	setEncoding(response.Headers, name)
+	return response
+}
+
+func setEncoding(header http.Header, name string) {
+	header.Set("Content-Encoding", name)
+	header.Del("Content-Length")
+	header.Del("Accept-Ranges")
+	if etag := header.Get("ETag"); strings.HasPrefix(etag, `"`) {
+		header.Set("ETag", strings.TrimSuffix(etag, `"`)+"-"+name+`"`)
+	}
+}
+
+// trimEncoding removes the suffix setEncoding adds to a strong tag.
+func trimEncoding(etag string) string {
+	tag, ok := strings.CutSuffix(etag, `"`)
+	if !ok || !strings.HasPrefix(tag, `"`) {
+		return etag
+	}
+
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+
+	for _, e := range encoders {
+		if trimmed, ok := strings.CutSuffix(tag, "-"+e.name); ok {
+			return trimmed + `"`
+		}
+	}
+	return etag
+}
+
+// trimEncodings returns headers with the tags of If-Match and If-None-Match
+// passed through trimEncoding. The original map is left untouched.
+func trimEncodings(headers map[string][]string) map[string][]string {
+	header := http.Header(headers)
+	if header.Get("If-Match") == "" && header.Get("If-None-Match") == "" {
+		return headers
+	}
+
+	header = header.Clone()
+	for _, key := range []string{"If-Match", "If-None-Match"} {
+		values := header.Values(key)
+		if len(values) == 0 {
+			continue
+		}
+
+		tags := make([]string, 0, len(values))
+		for _, value := range values {
+			for _, tag := range strings.Split(value, ",") {
+				tags = append(tags, trimEncoding(strings.TrimSpace(tag)))
+			}
+		}
+		header.Set(key, strings.Join(tags, ", "))
+	}
+	return header
+}
+
+func compressible(contentType string, opts CompressOptions) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, allowed := range opts.ContentTypes {
+		switch {
+		case strings.HasSuffix(allowed, "/") && strings.HasPrefix(mediaType, allowed),
+			strings.HasPrefix(allowed, "+") && strings.HasSuffix(mediaType, allowed),
+			mediaType == allowed:
+			return true
+		}
+	}
+	return false
+}
+
+func varyEncoding(headers http.Header) string {
+	vary := headers.Values("Vary")
+	for _, value := range vary {
+		for _, field := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), "Accept-Encoding") {
+				return strings.Join(vary, ", ")
+			}
+		}
+	}
+	return strings.Join(append(vary, "Accept-Encoding"), ", ")
+}
+
+// negotiateEncoding picks the registered coding with the highest q value
+// in Accept-Encoding. It returns a nil encoder when identity is preferred.
+func negotiateEncoding(accept string) (string, Encoder) {
+	if accept == "" {
+		return "", nil
+	}
+
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.TrimSpace(key) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		weights[name] = q
+	}
+
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+
+	var best encoding
+	var bestQ float64
+	wildcard, hasWildcard := weights["*"]
+	for i := len(encoders) - 1; i >= 0; i-- {
+		q, ok := weights[encoders[i].name]
+		if !ok && hasWildcard {
+			q = wildcard
+		}
+
+		if q > bestQ {
+			best, bestQ = encoders[i], q
+		}
+	}
+
+	if best.encoder == nil {
+		return "", nil
+	}
+
+	if identity, ok := weights["identity"]; ok && identity > bestQ {
+		return "", nil
+	}
+	return best.name, best.encoder
+}
+
+// compressWriter wraps a response writer callback, e.g. an SSE stream. The
+// decision to compress is made on the first write, once the callback has
+// set the status and Content-Type.
+func compressWriter(writer func(w http.ResponseWriter) error, headers http.Header, opts CompressOptions) func(w http.ResponseWriter) error {
+	return func(w http.ResponseWriter) error {
+		cw := &compressResponseWriter{ResponseWriter: w, accept: headers.Get("Accept-Encoding"), opts: opts}
+		err := writer(cw)
+		if closeErr := cw.close(); err == nil {
+			err = closeErr
+		}
+		return err
+	}
+}
+
+type compressResponseWriter struct {
+	http.ResponseWriter
+	accept     string
+	opts       CompressOptions
+	compressor Compressor
+	written    bool
+}
+
+func (w *compressResponseWriter) WriteHeader(status int) {
+	if w.written {
+		return
+	}
+	w.written = true
+
+	header := w.Header()
+	if status < 200 || status == http.StatusNoContent || status == http.StatusNotModified || status == http.StatusPartialContent ||
+		header.Get("Content-Encoding") != "" || !compressible(header.Get("Content-Type"), w.opts) {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	header.Set("Vary", varyEncoding(header))
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err == nil && length < w.opts.MinSize {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	name, encoder := negotiateEncoding(w.accept)
+	if encoder != nil {
+		if compressor, err := encoder(w.ResponseWriter, w.opts.Level); err == nil {
+			w.compressor = compressor
+			setEncoding(header, name)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *compressResponseWriter) Write(data []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.compressor == nil {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.compressor.Write(data)
+}
+
+// FlushError lets http.ResponseController flush the compressor before the
+// connection.
+func (w *compressResponseWriter) FlushError() error {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.compressor != nil {
+		if err := w.compressor.Flush(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *compressResponseWriter) Flush() {
+	w.FlushError()
+}
+
+func (w *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *compressResponseWriter) close() error {
+	if w.compressor == nil {
+		return nil
+	}
+	return w.compressor.Close()
+}
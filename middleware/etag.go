@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ramoncl001/comet/rest"
+)
+
+type ETagOptions struct {
+	// Weak marks computed tags as weak validators. Weak tags never satisfy
+	// If-Match, so it cannot be combined with Current.
+	Weak bool
+	// Current resolves the version of the resource addressed by the
+	// request, as a tag and a modification time. An empty tag means the
+	// resource does not exist; errors are answered with rest.FromError.
+	// Unquoted tags are taken as strong ones.
+	Current func(req *rest.Request) (string, time.Time, error)
+	// RequireIfMatch rejects PUT, PATCH and DELETE requests without
+	// If-Match or If-Unmodified-Since with 428. It requires Current.
+	RequireIfMatch bool
+}
+
+var DefaultETagOptions = ETagOptions{}
+
+// notModifiedHeaders are kept on 304 responses, as per RFC 9110.
+var notModifiedHeaders = []string{"Cache-Control", "Content-Location", "Date", "ETag", "Expires", "Last-Modified", "Vary"}
+
+// ETag adds validators to successful GET and HEAD responses and answers
+// conditional requests. The tag is the one set by the action with
+// WithETag, else the one resolved by Current, else a hash of the
+// serialized body. Streamed responses are only tagged by the first two.
+//
+// PUT, PATCH and DELETE preconditions are checked against Current before
+// the action runs, and fail with 412. Without Current only GET and HEAD
+// are handled. It can be installed globally, on groups or on actions, in
+// either order with Compress: coding suffixes are ignored when comparing.
+func ETag(opts ETagOptions) Middleware {
+	if opts.Weak && opts.Current != nil {
+		panic("middleware: weak ETags cannot be used with If-Match preconditions")
+	}
+
+	if opts.RequireIfMatch && opts.Current == nil {
+		panic("middleware: RequireIfMatch needs ETagOptions.Current")
+	}
+
+	return func(next rest.RequestHandler) rest.RequestHandler {
+		return func(req *rest.Request) rest.Response {
+			switch req.Method {
+			case http.MethodGet, http.MethodHead:
+				return conditionalGet(req, next(req), opts)
+			case http.MethodPut, http.MethodPatch, http.MethodDelete:
+				if opts.Current == nil {
+					return next(req)
+				}
+
+				headers := http.Header(trimEncodings(req.Headers))
+				if !hasPreconditions(headers) {
+					if opts.RequireIfMatch {
+						return rest.PreconditionRequired()
+					}
+					return next(req)
+				}
+
+				etag, modTime, err := currentVersion(req, opts)
+				if err != nil {
+					return rest.FromError(err)
+				}
+
+				if !rest.PreconditionsMet(headers, etag, modTime) {
+					return rest.PreconditionFailed()
+				}
+				return next(req)
+			default:
+				return next(req)
+			}
+		}
+	}
+}
+
+func hasPreconditions(headers http.Header) bool {
+	return headers.Get("If-Match") != "" || headers.Get("If-Unmodified-Since") != "" || headers.Get("If-None-Match") != ""
+}
+
+func currentVersion(req *rest.Request, opts ETagOptions) (string, time.Time, error) {
+	etag, modTime, err := opts.Current(req)
+	if err != nil || etag == "" {
+		return "", modTime, err
+	}
+	return rest.QuoteETag(etag), modTime, nil
+}
+
+func conditionalGet(req *rest.Request, response rest.Response, opts ETagOptions) rest.Response {
+	if response.Status != http.StatusOK {
+		return response
+	}
+
+	if response.Headers.Get("ETag") == "" && opts.Current != nil {
+		if etag, modTime, err := currentVersion(req, opts); err == nil && etag != "" {
+			response = response.WithHeader("ETag", etag)
+			if !modTime.IsZero() && response.Headers.Get("Last-Modified") == "" {
+				response = response.WithLastModified(modTime)
+			}
+		}
+	}
+
+	response = withETag(req, response, opts)
+	etag := trimEncoding(response.Headers.Get("ETag"))
+	modTime, _ := http.ParseTime(response.Headers.Get("Last-Modified"))
+	if !rest.NotModified(http.Header(trimEncodings(req.Headers)), etag, modTime) {
+		return response
+	}
+
+	if closer, ok := response.Body.(io.Closer); ok {
+		closer.Close()
+	}
+
+	headers := make(http.Header)
+	for _, key := range notModifiedHeaders {
+		if values := response.Headers.Values(key); len(values) > 0 {
+			headers[http.CanonicalHeaderKey(key)] = values
+		}
+	}
+	return rest.Response{Status: http.StatusNotModified, Headers: headers, Cookies: response.Cookies}
+}
+
+// withETag hashes the response body unless the action already set a tag.
+func withETag(req *rest.Request, response rest.Response, opts ETagOptions) rest.Response {
+	if response.Headers.Get("ETag") != "" {
+		return response
+	}
+
+	if response.Raw == nil && response.Data != nil {
+		encoded, err := encodeData(req, response)
+		if err != nil {
+			return response
+		}
+		response = encoded
+	}
+
+	if response.Raw == nil || response.Writer != nil {
+		return response
+	}
+
+	hash := sha256.Sum256(response.Raw)
+	etag := rest.StrongETag(hex.EncodeToString(hash[:16]))
+	if opts.Weak {
+		etag = "W/" + etag
+	}
+	return response.WithHeader("ETag", etag)
+}
+
+// encodeData serializes Data as the adapter would, keeping the result as
+// Raw so middlewares that need the body bytes do not encode it twice.
+func encodeData(req *rest.Request, response rest.Response) (rest.Response, error) {
+	contentType, data, err := serialize(http.Header(req.Headers).Get("Accept"), response)
+	if err != nil {
+		return response, err
+	}
+
+	response.Data = nil
+	response.Raw = data
+	response.Headers = response.Headers.Clone()
+	if response.Headers == nil {
+		response.Headers = make(http.Header)
+	}
+	response.Headers.Add("Vary", "Accept")
+	setHeader(response.Headers, "Content-Type", contentType)
+	return response, nil
+}
+
+func setHeader(headers http.Header, key, value string) {
+	if headers.Get(key) == "" {
+		headers.Set(key, value)
+	}
+}
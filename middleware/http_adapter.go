@@ -1,47 +1,210 @@
 package middleware
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/google/uuid"
+	"github.com/ramoncl001/comet/ioc"
 	"github.com/ramoncl001/comet/log"
 	"github.com/ramoncl001/comet/rest"
+	"github.com/ramoncl001/comet/serialization"
 )
 
 var HTTPAdapter = func(next rest.RequestHandler) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := rest.NewRequest(r)
 
-		bytes, err := io.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, "error parsing body", 500)
-			return
+		ctx := log.WithTraceID(r.Context(), uuid.New().String())
+		ctx = ioc.NewScope(ctx)
+		ctx = rest.WithRouteHolder(ctx)
+
+		response := next(request.WithContext(ctx))
+		if closer, ok := response.Body.(io.Closer); ok {
+			defer closer.Close()
 		}
 
-		request := rest.Request{
-			Url:           r.URL,
-			Method:        r.Method,
-			QueryParams:   r.URL.Query(),
-			PathParams:    make(map[string]string),
-			Headers:       r.Header,
-			Body:          bytes,
-			UserAgent:     r.UserAgent(),
-			RemoteAddress: r.RemoteAddr,
+		for key, values := range response.Headers {
+			w.Header()[key] = values
 		}
 
-		ctx := context.WithValue(r.Context(), log.TRACE_ID, uuid.New().String())
+		for _, cookie := range response.Cookies {
+			http.SetCookie(w, cookie)
+		}
 
-		response := next(request.WithContext(ctx))
+		if response.Writer != nil && r.Method != http.MethodHead {
+			writeStream(w, request.WithContext(ctx), response.Writer)
+			return
+		}
 
-		responseBytes, err := json.Marshal(response.Data)
-		if err != nil {
-			http.Error(w, "error deserializing response", 500)
+		// HEAD goes through the same encoding as GET, so it gets the same
+		// Content-Type and Content-Length, and only the body is dropped.
+		head := r.Method == http.MethodHead
+		if response.Writer != nil || !statusHasBody(response.Status) {
+			w.WriteHeader(response.Status)
 			return
 		}
 
-		w.WriteHeader(response.Status)
-		w.Write(responseBytes)
+		switch {
+		case response.Raw != nil:
+			setContentType(w, "application/octet-stream")
+			setContentLength(w, len(response.Raw))
+			w.WriteHeader(response.Status)
+			if !head {
+				w.Write(response.Raw)
+			}
+		case response.Body != nil:
+			setContentType(w, "application/octet-stream")
+			w.WriteHeader(response.Status)
+			if !head {
+				io.Copy(w, response.Body)
+			}
+		case response.Data == nil:
+			w.WriteHeader(response.Status)
+		default:
+			if problem, ok := response.Data.(rest.ProblemDetails); ok {
+				if problem.Instance == "" {
+					problem.Instance = r.URL.Path
+				}
+				if problem.TraceID == "" {
+					problem.TraceID = log.TraceIDFromContext(ctx)
+				}
+				response.Data = problem
+			}
+
+			contentType, responseBytes, err := serialize(r.Header.Get("Accept"), response)
+			if errors.Is(err, serialization.ErrNotAcceptable) {
+				writeProblem(w, ctx, r.URL.Path, rest.NewProblem(http.StatusNotAcceptable, "none of the accepted media types is supported"))
+				return
+			}
+
+			if err != nil {
+				log.FromContext(ctx).Error("error serializing response", "url", r.URL.String(), "error", err.Error())
+				writeProblem(w, ctx, r.URL.Path, rest.NewProblem(http.StatusInternalServerError, "error serializing response"))
+				return
+			}
+
+			w.Header().Add("Vary", "Accept")
+			setContentType(w, contentType)
+			setContentLength(w, len(responseBytes))
+			w.WriteHeader(response.Status)
+			if !head {
+				w.Write(responseBytes)
+			}
+		}
 	})
 }
+
+// writeStream hands the response over to writer. It runs after the
+// middleware chain, so panics are logged here as Recover would, then abort
+// the connection because the status has usually been sent already.
+func writeStream(w http.ResponseWriter, req *rest.Request, writer func(w http.ResponseWriter) error) {
+	defer func() {
+		if err := recover(); err != nil {
+			logPanic(req, err)
+			panic(http.ErrAbortHandler)
+		}
+	}()
+
+	if err := writer(w); err != nil {
+		log.FromContext(req.Context()).Error("error writing response", "url", req.Url.String(), "error", err.Error())
+	}
+}
+
+// writeProblem answers with problem details as JSON, for failures that
+// happen after the action, when the response could not be negotiated or
+// encoded.
+func writeProblem(w http.ResponseWriter, ctx context.Context, path string, problem rest.ProblemDetails) {
+	problem.Instance = path
+	problem.TraceID = log.TraceIDFromContext(ctx)
+
+	var buffer bytes.Buffer
+	if err := (serialization.JSON{}).Serialize(&buffer, problem); err != nil {
+		http.Error(w, problem.Title, problem.Status)
+		return
+	}
+
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(problem.Status)
+	w.Write(buffer.Bytes())
+}
+
+// serialize encodes Data in the format preferred by the client. When that
+// serializer cannot encode the value, e.g. XML and maps, the next acceptable
+// format is tried. Successful responses fail when no acceptable format can
+// encode the value; error responses fall back to the default format so the
+// client still gets a body.
+func serialize(accept string, response rest.Response) (string, []byte, error) {
+	acceptable := serialization.Acceptable(accept)
+	failures := make([]error, 0, len(acceptable))
+	for _, mediaType := range acceptable {
+		serializer, err := serialization.Lookup(mediaType)
+		if err != nil {
+			continue
+		}
+
+		var buffer bytes.Buffer
+		err = serializer.Serialize(&buffer, response.Data)
+		if err == nil {
+			return contentType(mediaType, response.Data), buffer.Bytes(), nil
+		}
+		failures = append(failures, fmt.Errorf("%s: %w", mediaType, err))
+	}
+
+	if response.Status < 400 {
+		if len(failures) > 0 {
+			return "", nil, errors.Join(failures...)
+		}
+		return "", nil, serialization.ErrNotAcceptable
+	}
+
+	serializer, err := serialization.Lookup(serialization.DefaultMediaType)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var buffer bytes.Buffer
+	if err := serializer.Serialize(&buffer, response.Data); err != nil {
+		return "", nil, err
+	}
+	return contentType(serialization.DefaultMediaType, response.Data), buffer.Bytes(), nil
+}
+
+// contentType uses the RFC 7807 media types for problem details.
+func contentType(mediaType string, data interface{}) string {
+	if _, ok := data.(rest.ProblemDetails); ok {
+		switch mediaType {
+		case "application/json":
+			return "application/problem+json"
+		case "application/xml", "text/xml":
+			return "application/problem+xml"
+		}
+	}
+	return serialization.ContentType(mediaType)
+}
+
+func setContentType(w http.ResponseWriter, contentType string) {
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+}
+
+func setContentLength(w http.ResponseWriter, length int) {
+	if w.Header().Get("Content-Length") == "" {
+		w.Header().Set("Content-Length", strconv.Itoa(length))
+	}
+}
+
+func bodyAllowed(method string, status int) bool {
+	return method != http.MethodHead && statusHasBody(status)
+}
+
+func statusHasBody(status int) bool {
+	return status != http.StatusNoContent && status != http.StatusNotModified
+}
@@ -5,3 +5,13 @@ import (
 )
 
 type Middleware func(next rest.RequestHandler) rest.RequestHandler
+
+// Config maps controller method names, or "*" for every action of the
+// controller, to the middlewares wrapped around them.
+type Config map[string][]Middleware
+
+// Provider is implemented by controllers that attach middlewares to their
+// own actions, analogous to rest.ControllerBase.Policies.
+type Provider interface {
+	Middlewares() Config
+}
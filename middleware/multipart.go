@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	"github.com/ramoncl001/comet/rest"
+)
+
+// Multipart sets the options used to parse form bodies for the wrapped
+// actions, including the parsing done implicitly by Bind.
+func Multipart(opts rest.MultipartOptions) Middleware {
+	return func(next rest.RequestHandler) rest.RequestHandler {
+		return func(req *rest.Request) rest.Response {
+			return next(req.WithContext(rest.WithMultipartOptions(req.Context(), opts)))
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"net/http"
 	"runtime/debug"
 	"time"
 
@@ -19,24 +20,29 @@ type panicLog struct {
 }
 
 var Recover Middleware = func(next rest.RequestHandler) rest.RequestHandler {
-	return func(req *rest.Request) rest.Response {
+	return func(req *rest.Request) (response rest.Response) {
 		defer func(req *rest.Request) {
 			if err := recover(); err != nil {
-				panicLog := panicLog{
-					Timestamp:     time.Now(),
-					URL:           req.Url.String(),
-					Method:        req.Method,
-					Error:         err,
-					StackTrace:    string(debug.Stack()),
-					UserAgent:     req.UserAgent,
-					RemoteAddress: req.RemoteAddress,
-				}
-
-				logger := log.FromContext(req.Context())
-
-				logger.Error("panic error received from request", "info", panicLog)
+				logPanic(req, err)
+				response = rest.Problem(http.StatusInternalServerError, "")
 			}
 		}(req)
 		return next(req)
 	}
 }
+
+func logPanic(req *rest.Request, err interface{}) {
+	panicLog := panicLog{
+		Timestamp:     time.Now(),
+		URL:           req.Url.String(),
+		Method:        req.Method,
+		Error:         err,
+		StackTrace:    string(debug.Stack()),
+		UserAgent:     req.UserAgent,
+		RemoteAddress: req.RemoteAddress,
+	}
+
+	logger := log.FromContext(req.Context())
+
+	logger.Error("panic error received from request", "info", panicLog)
+}
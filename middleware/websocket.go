@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"github.com/ramoncl001/comet/rest"
+)
+
+// WebSocketConfig sets the options used by the wrapped websocket actions in
+// place of rest.DefaultWebSocketOptions. Zero fields are used as given,
+// except for ReadLimit, which takes the default limit.
+func WebSocketConfig(opts rest.WebSocketOptions) Middleware {
+	return func(next rest.RequestHandler) rest.RequestHandler {
+		return func(req *rest.Request) rest.Response {
+			return next(req.WithContext(rest.WithWebSocketOptions(req.Context(), opts)))
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package rest
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ramoncl001/comet/serialization"
+	"github.com/ramoncl001/comet/validation"
+)
+
+// FieldError is shared with validation, so binding and validation
+// failures are reported with the same shape.
+type FieldError = validation.FieldError
+
+type BindingError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *BindingError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		if err.Field == "" {
+			messages = append(messages, err.Message)
+			continue
+		}
+		messages = append(messages, err.Field+": "+err.Message)
+	}
+	return "binding failed: " + strings.Join(messages, "; ")
+}
+
+// InvalidInput turns a binding or validation failure into a problem
+// response carrying the per-field details. Oversized bodies and
+// unsupported content types map to 413 and 415.
+func InvalidInput(err error) Response {
+	if response, ok := mapError(err); ok {
+		return response
+	}
+	return Problem(http.StatusBadRequest, err.Error())
+}
+
+var bindSources = []string{"path", "query", "header", "form"}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	formFileType      = reflect.TypeOf((*FormFile)(nil))
+	durationType      = reflect.TypeOf(time.Duration(0))
+	textUnmarshalType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Bind builds a T from the request. The body is decoded first, according
+// to its Content-Type, then fields tagged with `path`, `query`, `header` or
+// `form` are filled from the matching part of the request. Finally the
+// result is checked with validation.Validate.
+func Bind[T any](req *Request) (T, error) {
+	var target T
+	err := BindTo(req, &target)
+	return target, err
+}
+
+func BindTo(req *Request, target interface{}) error {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return fmt.Errorf("bind target must be a non-nil pointer, got %T", target)
+	}
+
+	result := &BindingError{}
+	form, err := bindBody(req, target)
+	if errors.Is(err, ErrBodyTooLarge) || errors.Is(err, ErrFileTooLarge) || errors.Is(err, serialization.ErrUnsupportedMediaType) {
+		return err
+	}
+
+	if form == nil {
+		form = &Form{}
+	}
+
+	if err != nil {
+		result.Errors = append(result.Errors, FieldError{Source: "body", Message: err.Error()})
+	}
+
+	elem := value.Elem()
+	if elem.Kind() == reflect.Struct {
+		sources := map[string]func(string) ([]string, bool){
+			"path": func(key string) ([]string, bool) {
+				v, ok := req.PathParams[key]
+				return []string{v}, ok
+			},
+			"query": func(key string) ([]string, bool) {
+				v, ok := req.QueryParams[key]
+				return v, ok
+			},
+			"header": func(key string) ([]string, bool) {
+				v, ok := req.Headers[http.CanonicalHeaderKey(key)]
+				return v, ok
+			},
+			"form": func(key string) ([]string, bool) {
+				v, ok := form.Value[key]
+				return v, ok
+			},
+		}
+		bindFields(elem, sources, form.File, result)
+	}
+
+	if len(result.Errors) > 0 {
+		return result
+	}
+
+	if err := validation.Validate(target); err != nil {
+		var invalid validation.Errors
+		if !errors.As(err, &invalid) {
+			return err
+		}
+
+		result.Errors = append(result.Errors, invalid...)
+		return result
+	}
+	return nil
+}
+
+func bindBody(req *Request, target interface{}) (*Form, error) {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return nil, nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(http.Header(req.Headers).Get("Content-Type"))
+	if mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data" {
+		return req.MultipartForm()
+	}
+
+	body, err := req.ReadBody()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(body) == 0 {
+		return nil, nil
+	}
+
+	serializer, err := serialization.Lookup(mediaType)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q", err, mediaType)
+	}
+	return nil, serializer.Deserialize(bytes.NewReader(body), target)
+}
+
+func bindFields(value reflect.Value, sources map[string]func(string) ([]string, bool), files map[string][]*FormFile, result *BindingError) {
+	typ := value.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		fieldValue := value.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			bindFields(fieldValue, sources, files, result)
+			continue
+		}
+
+		if key, ok := field.Tag.Lookup("form"); ok && bindFiles(fieldValue, files[key]) {
+			continue
+		}
+
+		for _, source := range bindSources {
+			key, ok := field.Tag.Lookup(source)
+			if !ok || key == "-" {
+				continue
+			}
+
+			values, ok := sources[source](key)
+			if !ok || len(values) == 0 {
+				continue
+			}
+
+			if err := setValue(fieldValue, values); err != nil {
+				result.Errors = append(result.Errors, FieldError{Field: key, Source: source, Message: err.Error()})
+			}
+		}
+	}
+}
+
+// bindFiles fills *FormFile and []*FormFile fields with uploaded files. It
+// reports whether the field holds files, so it is not bound as a value.
+func bindFiles(field reflect.Value, files []*FormFile) bool {
+	switch {
+	case field.Type() == formFileType:
+		if len(files) > 0 {
+			field.Set(reflect.ValueOf(files[0]))
+		}
+	case field.Kind() == reflect.Slice && field.Type().Elem() == formFileType:
+		if len(files) > 0 {
+			field.Set(reflect.ValueOf(files))
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+func setValue(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Ptr {
+		target := reflect.New(field.Type().Elem())
+		if err := setValue(target.Elem(), values); err != nil {
+			return err
+		}
+		field.Set(target)
+		return nil
+	}
+
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+		if len(values) == 1 {
+			values = strings.Split(values[0], ",")
+		}
+
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, v := range values {
+			if err := setValue(slice.Index(i), []string{strings.TrimSpace(v)}); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	return setScalar(field, values[0])
+}
+
+func setScalar(field reflect.Value, raw string) error {
+	if field.CanAddr() && field.Addr().Type().Implements(textUnmarshalType) && field.Type() != timeType {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(raw))
+	}
+
+	switch field.Type() {
+	case timeType:
+		for _, layout := range []string{time.RFC3339Nano, time.DateTime, time.DateOnly} {
+			if t, err := time.Parse(layout, raw); err == nil {
+				field.Set(reflect.ValueOf(t))
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not a valid time", raw)
+	case durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a valid duration", raw)
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported field type %s", field.Type())
+		}
+		field.SetBytes([]byte(raw))
+	case reflect.Bool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a valid boolean", raw)
+		}
+		field.SetBool(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(raw, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not a valid integer", raw)
+		}
+		field.SetInt(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(raw, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not a valid unsigned integer", raw)
+		}
+		field.SetUint(v)
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(raw, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not a valid number", raw)
+		}
+		field.SetFloat(v)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package rest
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+)
+
+var (
+	ErrBodyTooLarge = errors.New("request body too large")
+	ErrFileTooLarge = errors.New("uploaded file too large")
+)
+
+// requestBody is shared by every copy of a Request made with WithContext,
+// so the underlying stream is consumed at most once.
+type requestBody struct {
+	mu       sync.Mutex
+	reader   io.Reader
+	length   int64
+	limit    int64
+	data     []byte
+	buffered bool
+	streamed bool
+	err      error
+
+	formOnce sync.Once
+	form     *Form
+	formErr  error
+}
+
+func newRequestBody(reader io.Reader, length int64) *requestBody {
+	if reader == nil {
+		reader = http.NoBody
+	}
+	return &requestBody{reader: reader, length: length, limit: -1}
+}
+
+func (b *requestBody) read() ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.buffered {
+		return b.data, b.err
+	}
+
+	b.data, b.err = io.ReadAll(b.limited())
+	b.buffered = true
+	return b.data, b.err
+}
+
+// cached returns the body if it has already been read successfully.
+func (b *requestBody) cached() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.buffered && b.err == nil {
+		return b.data
+	}
+	return nil
+}
+
+func (b *requestBody) stream() io.Reader {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.buffered {
+		return bytes.NewReader(b.data)
+	}
+
+	b.streamed = true
+	return b.limited()
+}
+
+// limited applies the limit in effect when reading starts. A declared
+// Content-Length over the limit fails before anything is read.
+func (b *requestBody) limited() io.Reader {
+	if b.limit < 0 {
+		return b.reader
+	}
+
+	if b.length > b.limit {
+		return &limitedReader{reader: b.reader, remaining: -1, err: ErrBodyTooLarge}
+	}
+	return &limitedReader{reader: b.reader, remaining: b.limit, err: ErrBodyTooLarge}
+}
+
+// limitedReader fails with err instead of silently truncating the stream
+// like io.LimitReader does.
+type limitedReader struct {
+	reader    io.Reader
+	remaining int64
+	err       error
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, l.err
+	}
+
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+
+	n, err := l.reader.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n + int(l.remaining), l.err
+	}
+	return n, err
+}
+
+// ReadBody reads the whole request body on first use and keeps it in the
+// Body field. The router calls it before running actions that take the
+// request, so middlewares only find Body set after reading it themselves.
+func (r *Request) ReadBody() ([]byte, error) {
+	if r.body == nil {
+		return r.Body, nil
+	}
+
+	data, err := r.body.read()
+	if err == nil {
+		r.Body = data
+	}
+	return data, err
+}
+
+// BodyReader streams the request body without buffering it. Once streamed,
+// the body cannot be read again through ReadBody. Actions that stream the
+// body take an io.Reader parameter, so it is not read into Body first.
+func (r *Request) BodyReader() io.Reader {
+	if r.body == nil {
+		return http.NoBody
+	}
+	return r.body.stream()
+}
+
+// LimitBody caps the number of bytes that can be read from the body,
+// replacing any previous limit. Reads past the limit, or of a body whose
+// Content-Length exceeds it, fail with ErrBodyTooLarge. It has no effect
+// once the body has been read.
+func (r *Request) LimitBody(limit int64) {
+	if r.body == nil {
+		return
+	}
+
+	r.body.mu.Lock()
+	defer r.body.mu.Unlock()
+
+	if !r.body.buffered && !r.body.streamed {
+		r.body.limit = limit
+	}
+}
@@ -0,0 +1,94 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// StrongETag quotes version as a strong entity tag, for byte-identical
+// representations.
+func StrongETag(version string) string {
+	return `"` + version + `"`
+}
+
+// WeakETag quotes version as a weak entity tag, for semantically equivalent
+// representations.
+func WeakETag(version string) string {
+	return `W/"` + version + `"`
+}
+
+// QuoteETag returns etag as an entity tag. Unquoted values are treated as
+// strong tags.
+func QuoteETag(etag string) string {
+	if !strings.HasPrefix(etag, `"`) && !strings.HasPrefix(etag, `W/"`) {
+		return StrongETag(etag)
+	}
+	return etag
+}
+
+// WithETag sets the ETag header, quoting it with QuoteETag.
+func (r Response) WithETag(etag string) Response {
+	return r.WithHeader("ETag", QuoteETag(etag))
+}
+
+func (r Response) WithLastModified(modTime time.Time) Response {
+	return r.WithHeader("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+}
+
+// NotModified evaluates If-None-Match, or If-Modified-Since when there is
+// none, against the current validators of a resource.
+func NotModified(headers http.Header, etag string, modTime time.Time) bool {
+	if match := headers.Get("If-None-Match"); match != "" {
+		return etag != "" && ETagMatches(match, etag, true)
+	}
+
+	since, err := http.ParseTime(headers.Get("If-Modified-Since"))
+	if err != nil || modTime.IsZero() {
+		return false
+	}
+	return !modTime.Truncate(time.Second).After(since)
+}
+
+// PreconditionsMet evaluates If-Match, or If-Unmodified-Since when there is
+// none, and If-None-Match for state-changing requests. An empty etag means
+// the resource does not exist.
+func PreconditionsMet(headers http.Header, etag string, modTime time.Time) bool {
+	if match := headers.Get("If-Match"); match != "" {
+		if etag == "" || !ETagMatches(match, etag, false) {
+			return false
+		}
+	} else if since, err := http.ParseTime(headers.Get("If-Unmodified-Since")); err == nil {
+		if modTime.IsZero() || modTime.Truncate(time.Second).After(since) {
+			return false
+		}
+	}
+
+	if match := headers.Get("If-None-Match"); match != "" {
+		return etag == "" || !ETagMatches(match, etag, true)
+	}
+	return true
+}
+
+// ETagMatches reports whether etag appears in a comma separated list of
+// entity tags, using weak comparison when weak is true.
+func ETagMatches(list, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+			etag = strings.TrimPrefix(etag, "W/")
+		} else if strings.HasPrefix(candidate, "W/") || strings.HasPrefix(etag, "W/") {
+			continue
+		}
+
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ramoncl001/comet/log"
+)
+
+// Claims are the authenticated claims of the current user.
+type Claims map[string]interface{}
+
+// Deprecated: USER_ID and REQUEST_ID are the raw context keys still
+// written next to the typed ones, for code reading them directly. Use
+// UserIDFromContext and RequestIDFromContext instead.
+const (
+	USER_ID    string = "user_id"
+	REQUEST_ID string = "X-Request-Id"
+)
+
+type contextKey int
+
+const (
+	userIDKey contextKey = iota
+	claimsKey
+	requestIDKey
+	routeTemplateKey
+	multipartOptionsKey
+	webSocketOptionsKey
+)
+
+func WithUserID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, USER_ID, id)
+	return context.WithValue(ctx, userIDKey, id)
+}
+
+// UserIDFromContext falls back to the deprecated USER_ID key, which
+// custom authentication middlewares may still set.
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	if id, ok := contextValue[string](ctx, userIDKey); ok {
+		return id, true
+	}
+	return rawValue(ctx, USER_ID)
+}
+
+// WithClaims stores the claims along with the user id taken from the
+// "sub" claim.
+func WithClaims(ctx context.Context, claims Claims) context.Context {
+	ctx = context.WithValue(ctx, claimsKey, claims)
+	if sub, ok := claims["sub"]; ok && sub != nil {
+		ctx = WithUserID(ctx, fmt.Sprint(sub))
+	}
+	return ctx
+}
+
+func ClaimsFromContext(ctx context.Context) (Claims, bool) {
+	return contextValue[Claims](ctx, claimsKey)
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, REQUEST_ID, id)
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	if id, ok := contextValue[string](ctx, requestIDKey); ok {
+		return id, true
+	}
+	return rawValue(ctx, REQUEST_ID)
+}
+
+// routeHolder is shared by every context derived from the request, so the
+// route matched by the router is also visible to the middlewares wrapped
+// around it, after they call next.
+type routeHolder struct {
+	mu       sync.RWMutex
+	template string
+}
+
+// WithRouteHolder prepares ctx to receive the route matched later on. The
+// HTTP adapter calls it for every request.
+func WithRouteHolder(ctx context.Context) context.Context {
+	return context.WithValue(ctx, routeTemplateKey, &routeHolder{})
+}
+
+// WithRouteTemplate records the matched route in the holder of ctx, or in
+// a new one when ctx has none.
+func WithRouteTemplate(ctx context.Context, template string) context.Context {
+	if holder, ok := contextValue[*routeHolder](ctx, routeTemplateKey); ok {
+		holder.mu.Lock()
+		holder.template = template
+		holder.mu.Unlock()
+		return ctx
+	}
+	return context.WithValue(ctx, routeTemplateKey, &routeHolder{template: template})
+}
+
+func RouteTemplateFromContext(ctx context.Context) (string, bool) {
+	holder, ok := contextValue[*routeHolder](ctx, routeTemplateKey)
+	if !ok {
+		return "", false
+	}
+
+	holder.mu.RLock()
+	defer holder.mu.RUnlock()
+	return holder.template, holder.template != ""
+}
+
+func contextValue[T any](ctx context.Context, key contextKey) (T, bool) {
+	if ctx == nil {
+		return *new(T), false
+	}
+
+	value, ok := ctx.Value(key).(T)
+	return value, ok
+}
+
+func rawValue(ctx context.Context, key string) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+
+	value := ctx.Value(key)
+	if value == nil {
+		return "", false
+	}
+	return fmt.Sprint(value), true
+}
+
+// UserID returns the id of the authenticated user, or "" for anonymous
+// requests.
+func (r *Request) UserID() string {
+	id, _ := UserIDFromContext(r.ctx)
+	return id
+}
+
+func (r *Request) Claims() Claims {
+	claims, _ := ClaimsFromContext(r.ctx)
+	return claims
+}
+
+func (r *Request) RequestID() string {
+	id, _ := RequestIDFromContext(r.ctx)
+	return id
+}
+
+func (r *Request) TraceID() string {
+	return log.TraceIDFromContext(r.ctx)
+}
+
+// RouteTemplate returns the template of the matched route, such as
+// "/users/:id", which unlike the path is safe to use as a metric label.
+// Middlewares installed with UseMiddleware see it once next returns.
+func (r *Request) RouteTemplate() string {
+	template, _ := RouteTemplateFromContext(r.ctx)
+	return template
+}
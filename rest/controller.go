@@ -6,3 +6,29 @@ type ControllerBase interface {
 }
 
 type RequestHandler func(*Request) Response
+
+// RouteProvider lets a controller declare its routes explicitly instead of
+// relying on method-name conventions. Methods missing from the table keep
+// the convention-based path.
+type RouteProvider interface {
+	Routes() RoutesConfig
+}
+
+type RoutesConfig map[string]RouteDefinition
+
+// RouteDefinition binds a controller method to a verb and a path template.
+// Templates starting with "/" are absolute, anything else is appended to
+// the controller route. Parameters such as ":id" or ":id{int}" fill a whole
+// segment; a final catch-all such as "*path" takes the rest of the path and
+// also matches an empty rest, so "/files/*path" matches "/files/".
+type RouteDefinition struct {
+	Method RequestMethod
+	Path   string
+}
+
+func Route(method RequestMethod, path string) RouteDefinition {
+	return RouteDefinition{
+		Method: method,
+		Path:   path,
+	}
+}
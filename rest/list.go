@@ -0,0 +1,314 @@
+package rest
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Filter operators accepted in filter[field][op]=value. A filter without
+// an operator uses FilterEq; FilterIn takes a comma separated list.
+const (
+	FilterEq   = "eq"
+	FilterNe   = "ne"
+	FilterGt   = "gt"
+	FilterGte  = "gte"
+	FilterLt   = "lt"
+	FilterLte  = "lte"
+	FilterLike = "like"
+	FilterIn   = "in"
+)
+
+// ListField whitelists a field for sorting and filtering.
+type ListField struct {
+	// Column is the database column. Defaults to the field name.
+	Column   string
+	Sortable bool
+	// Filters lists the allowed operators.
+	Filters []string
+}
+
+type ListOptions struct {
+	Fields map[string]ListField
+	// DefaultSort applies when the request has no sort, e.g. "-createdAt".
+	DefaultSort     string
+	DefaultPageSize int
+	MaxPageSize     int
+}
+
+var DefaultListOptions = ListOptions{
+	DefaultPageSize: 20,
+	MaxPageSize:     100,
+}
+
+type SortField struct {
+	Field  string
+	Column string
+	Desc   bool
+}
+
+type Filter struct {
+	Field  string
+	Column string
+	Op     string
+	Value  string
+}
+
+// ListQuery is the parsed form of
+// ?page=2&pageSize=20&sort=-createdAt,name&filter[status][in]=open,closed.
+// Cursor replaces page when set. It is an opaque page token wrapping an
+// offset rather than a keyset cursor, so rows inserted or deleted before
+// it shift the following pages just like page numbers do.
+type ListQuery struct {
+	Page     int
+	PageSize int
+	Cursor   string
+	Sort     []SortField
+	Filters  []Filter
+	offset   int
+}
+
+// maxOffset bounds the rows skipped by a page or cursor, which keeps
+// (page-1)*pageSize from overflowing.
+const maxOffset = math.MaxInt32
+
+var filterPattern = regexp.MustCompile(`^filter\[([^\[\]]+)\](?:\[([a-z]+)\])?$`)
+
+// ParseListQuery reads paging, sorting and filtering parameters, rejecting
+// fields and operators missing from the whitelist with a *BindingError.
+func ParseListQuery(req *Request, opts ListOptions) (ListQuery, error) {
+	if opts.DefaultPageSize <= 0 {
+		opts.DefaultPageSize = DefaultListOptions.DefaultPageSize
+	}
+
+	if opts.MaxPageSize <= 0 {
+		opts.MaxPageSize = DefaultListOptions.MaxPageSize
+	}
+
+	query := url.Values(req.QueryParams)
+	result := ListQuery{Page: 1, PageSize: opts.DefaultPageSize}
+	errs := &BindingError{}
+	invalid := func(field, message string) {
+		errs.Errors = append(errs.Errors, FieldError{Field: field, Source: "query", Message: message})
+	}
+
+	if raw := query.Get("page"); raw != "" {
+		page, err := strconv.Atoi(raw)
+		if err != nil || page < 1 {
+			invalid("page", "must be a positive integer")
+		}
+		result.Page = page
+	}
+
+	if raw := query.Get("pageSize"); raw != "" {
+		size, err := strconv.Atoi(raw)
+		if err != nil || size < 1 || size > opts.MaxPageSize {
+			invalid("pageSize", fmt.Sprintf("must be between 1 and %d", opts.MaxPageSize))
+		}
+		result.PageSize = size
+	}
+
+	if result.PageSize > 0 && result.Page-1 > maxOffset/result.PageSize {
+		invalid("page", "is too large")
+	} else {
+		result.offset = (result.Page - 1) * result.PageSize
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		offset, err := decodeCursor(cursor)
+		if err != nil {
+			invalid("cursor", "is not a valid cursor")
+		}
+		result.Cursor = cursor
+		result.offset = offset
+	}
+
+	sortSpec := query.Get("sort")
+	if sortSpec == "" {
+		sortSpec = opts.DefaultSort
+	}
+
+	for _, item := range strings.Split(sortSpec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, desc := strings.CutPrefix(item, "-")
+		name = strings.TrimPrefix(name, "+")
+		field, ok := opts.Fields[name]
+		if !ok || !field.Sortable {
+			invalid("sort", fmt.Sprintf("cannot sort by %q", name))
+			continue
+		}
+		result.Sort = append(result.Sort, SortField{Field: name, Column: field.column(name), Desc: desc})
+	}
+
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		match := filterPattern.FindStringSubmatch(key)
+		if match == nil {
+			if strings.HasPrefix(key, "filter[") {
+				invalid(key, "is not a valid filter")
+			}
+			continue
+		}
+
+		name, op := match[1], match[2]
+		if op == "" {
+			op = FilterEq
+		}
+
+		field, ok := opts.Fields[name]
+		if !ok || !slices.Contains(field.Filters, op) {
+			invalid(key, fmt.Sprintf("cannot filter %q with %q", name, op))
+			continue
+		}
+
+		for _, value := range query[key] {
+			result.Filters = append(result.Filters, Filter{Field: name, Column: field.column(name), Op: op, Value: value})
+		}
+	}
+
+	if len(errs.Errors) > 0 {
+		return ListQuery{}, errs
+	}
+	return result, nil
+}
+
+func (f ListField) column(name string) string {
+	if f.Column != "" {
+		return f.Column
+	}
+	return name
+}
+
+// Offset is the number of items to skip, derived from the cursor or the
+// page.
+func (q ListQuery) Offset() int {
+	return q.offset
+}
+
+// Values splits the value of FilterIn filters.
+func (f Filter) Values() []string {
+	if f.Op != FilterIn {
+		return []string{f.Value}
+	}
+	return strings.Split(f.Value, ",")
+}
+
+// encodeCursor builds the page token for offset. The token is opaque to
+// clients, so its format may change without breaking them.
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("o:" + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+
+	value, ok := strings.CutPrefix(string(raw), "o:")
+	if !ok {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+
+	offset, err := strconv.Atoi(value)
+	if err != nil || offset < 0 || offset > maxOffset {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	return offset, nil
+}
+
+type PageLinks struct {
+	Self string `json:"self"`
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+// Page is the envelope returned by LIST actions. NextCursor is the page
+// token of the following page, see ListQuery.
+type Page[T any] struct {
+	Items      []T       `json:"items"`
+	Total      int64     `json:"total"`
+	Page       int       `json:"page,omitempty"`
+	PageSize   int       `json:"pageSize"`
+	NextCursor string    `json:"nextCursor,omitempty"`
+	Links      PageLinks `json:"links"`
+}
+
+// NewPage builds the envelope for items, with links that keep the query
+// of the current request. Requests paging with a cursor get cursor links.
+func NewPage[T any](req *Request, query ListQuery, items []T, total int64) Page[T] {
+	if items == nil {
+		items = []T{}
+	}
+
+	page := Page[T]{
+		Items:    items,
+		Total:    total,
+		PageSize: query.PageSize,
+		Links:    PageLinks{Self: pageLink(req, nil)},
+	}
+
+	offset := query.Offset()
+	hasNext := int64(offset+len(items)) < total
+	hasPrev := offset > 0
+
+	if query.Cursor != "" {
+		if hasNext {
+			page.NextCursor = encodeCursor(offset + query.PageSize)
+			page.Links.Next = pageLink(req, map[string]string{"cursor": page.NextCursor})
+		}
+		if hasPrev {
+			page.Links.Prev = pageLink(req, map[string]string{"cursor": encodeCursor(max(offset-query.PageSize, 0))})
+		}
+		return page
+	}
+
+	page.Page = query.Page
+	if hasNext {
+		page.NextCursor = encodeCursor(offset + query.PageSize)
+		page.Links.Next = pageLink(req, map[string]string{"page": strconv.Itoa(query.Page + 1)})
+	}
+	if hasPrev {
+		page.Links.Prev = pageLink(req, map[string]string{"page": strconv.Itoa(query.Page - 1)})
+	}
+	return page
+}
+
+func pageLink(req *Request, set map[string]string) string {
+	query := url.Values{}
+	for key, values := range req.QueryParams {
+		query[key] = values
+	}
+
+	for key, value := range set {
+		query.Set(key, value)
+		if key == "cursor" {
+			query.Del("page")
+		}
+	}
+
+	path := ""
+	if req.Url != nil {
+		path = req.Url.Path
+	}
+
+	if encoded := query.Encode(); encoded != "" {
+		return path + "?" + encoded
+	}
+	return path
+}
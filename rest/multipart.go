@@ -0,0 +1,285 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+)
+
+// MultipartOptions limits form parsing. Zero fields take their value from
+// DefaultMultipartOptions, where zero limits mean no limit.
+type MultipartOptions struct {
+	// MaxMemory is the number of bytes kept in memory across all parts.
+	// Larger files spill over to temporary files.
+	MaxMemory int64
+	// MaxFileSize limits every single file.
+	MaxFileSize int64
+	// MaxTotalSize limits the whole request body.
+	MaxTotalSize int64
+}
+
+var DefaultMultipartOptions = MultipartOptions{
+	MaxMemory: 32 << 20,
+}
+
+type Form struct {
+	Value url.Values
+	File  map[string][]*FormFile
+}
+
+// RemoveAll deletes the temporary files backing the form. It is called
+// automatically when the request context ends.
+func (f *Form) RemoveAll() {
+	for _, files := range f.File {
+		for _, file := range files {
+			if file.tmpfile != "" {
+				os.Remove(file.tmpfile)
+			}
+		}
+	}
+}
+
+type FormFile struct {
+	Filename string
+	Header   textproto.MIMEHeader
+	Size     int64
+	content  []byte
+	tmpfile  string
+}
+
+type bytesFile struct {
+	*bytes.Reader
+}
+
+func (bytesFile) Close() error {
+	return nil
+}
+
+func (f *FormFile) Open() (multipart.File, error) {
+	if f.tmpfile != "" {
+		return os.Open(f.tmpfile)
+	}
+	return bytesFile{bytes.NewReader(f.content)}, nil
+}
+
+// ParseMultipartForm reads the form with the given options. Zero fields
+// take their value from DefaultMultipartOptions. Only the first call has an
+// effect; later calls, as well as FormValue, File and Bind, reuse the parsed
+// form.
+func (r *Request) ParseMultipartForm(opts MultipartOptions) (*Form, error) {
+	if r.body == nil {
+		return nil, http.ErrNotMultipart
+	}
+
+	r.body.formOnce.Do(func() {
+		r.body.form, r.body.formErr = r.parseForm(opts.withDefaults())
+	})
+	return r.body.form, r.body.formErr
+}
+
+// MultipartForm parses the form with the options set for the route with
+// WithMultipartOptions, or DefaultMultipartOptions.
+func (r *Request) MultipartForm() (*Form, error) {
+	opts := DefaultMultipartOptions
+	if r.ctx != nil {
+		if configured, ok := MultipartOptionsFromContext(r.ctx); ok {
+			opts = configured
+		}
+	}
+	return r.ParseMultipartForm(opts)
+}
+
+// WithMultipartOptions sets the options used when the form is parsed
+// implicitly, by Bind, FormValue or File.
+func WithMultipartOptions(ctx context.Context, opts MultipartOptions) context.Context {
+	return context.WithValue(ctx, multipartOptionsKey, opts)
+}
+
+func MultipartOptionsFromContext(ctx context.Context) (MultipartOptions, bool) {
+	return contextValue[MultipartOptions](ctx, multipartOptionsKey)
+}
+
+func (o MultipartOptions) withDefaults() MultipartOptions {
+	if o.MaxMemory <= 0 {
+		o.MaxMemory = DefaultMultipartOptions.MaxMemory
+	}
+
+	if o.MaxFileSize <= 0 {
+		o.MaxFileSize = DefaultMultipartOptions.MaxFileSize
+	}
+
+	if o.MaxTotalSize <= 0 {
+		o.MaxTotalSize = DefaultMultipartOptions.MaxTotalSize
+	}
+	return o
+}
+
+// FormValue returns the first value for name in the form body, falling back
+// to the query string.
+func (r *Request) FormValue(name string) string {
+	if form, err := r.MultipartForm(); err == nil {
+		if values := form.Value[name]; len(values) > 0 {
+			return values[0]
+		}
+	}
+
+	if values := r.QueryParams[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (r *Request) File(name string) (multipart.File, *FormFile, error) {
+	form, err := r.MultipartForm()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files := form.File[name]
+	if len(files) == 0 {
+		return nil, nil, http.ErrMissingFile
+	}
+
+	file, err := files[0].Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, files[0], nil
+}
+
+func (r *Request) parseForm(opts MultipartOptions) (*Form, error) {
+	mediaType, params, _ := mime.ParseMediaType(http.Header(r.Headers).Get("Content-Type"))
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		body, err := r.ReadBody()
+		if err != nil {
+			return nil, err
+		}
+
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, err
+		}
+		return &Form{Value: values, File: map[string][]*FormFile{}}, nil
+	case "multipart/form-data":
+	default:
+		return nil, http.ErrNotMultipart
+	}
+
+	boundary := params["boundary"]
+	if boundary == "" {
+		return nil, http.ErrMissingBoundary
+	}
+
+	if opts.MaxTotalSize > 0 {
+		r.LimitBody(opts.MaxTotalSize)
+	}
+
+	form, err := readMultipart(multipart.NewReader(r.BodyReader(), boundary), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.ctx != nil {
+		context.AfterFunc(r.ctx, form.RemoveAll)
+	}
+	return form, nil
+}
+
+func readMultipart(reader *multipart.Reader, opts MultipartOptions) (*Form, error) {
+	form := &Form{Value: url.Values{}, File: map[string][]*FormFile{}}
+	memory := opts.MaxMemory
+
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return form, nil
+		}
+
+		if err != nil {
+			form.RemoveAll()
+			return nil, err
+		}
+
+		name := part.FormName()
+		if name == "" {
+			part.Close()
+			continue
+		}
+
+		if part.FileName() == "" {
+			var value bytes.Buffer
+			n, err := io.Copy(&value, io.LimitReader(part, memory+1))
+			part.Close()
+			if err != nil {
+				form.RemoveAll()
+				return nil, err
+			}
+
+			memory -= n
+			if memory < 0 {
+				form.RemoveAll()
+				return nil, ErrBodyTooLarge
+			}
+
+			form.Value[name] = append(form.Value[name], value.String())
+			continue
+		}
+
+		file, err := readFormFile(part, &memory, opts.MaxFileSize)
+		part.Close()
+		if err != nil {
+			form.RemoveAll()
+			return nil, err
+		}
+		form.File[name] = append(form.File[name], file)
+	}
+}
+
+func readFormFile(part *multipart.Part, memory *int64, maxSize int64) (*FormFile, error) {
+	file := &FormFile{Filename: part.FileName(), Header: part.Header}
+
+	var source io.Reader = part
+	if maxSize > 0 {
+		source = &limitedReader{reader: part, remaining: maxSize, err: ErrFileTooLarge}
+	}
+
+	var buffer bytes.Buffer
+	n, err := io.Copy(&buffer, io.LimitReader(source, *memory+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if n <= *memory {
+		*memory -= n
+		file.content = buffer.Bytes()
+		file.Size = n
+		return file, nil
+	}
+
+	tmp, err := os.CreateTemp("", "comet-multipart-")
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := io.Copy(tmp, io.MultiReader(&buffer, source))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	file.tmpfile = tmp.Name()
+	file.Size = size
+	return file, nil
+}
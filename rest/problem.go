@@ -0,0 +1,200 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/ramoncl001/comet/serialization"
+	"github.com/ramoncl001/comet/validation"
+)
+
+// ProblemDetails is an RFC 7807 error body. Extensions are written as
+// additional top-level members.
+type ProblemDetails struct {
+	Type       string                 `json:"type" xml:"type"`
+	Title      string                 `json:"title" xml:"title"`
+	Status     int                    `json:"status" xml:"status"`
+	Detail     string                 `json:"detail,omitempty" xml:"detail,omitempty"`
+	Instance   string                 `json:"instance,omitempty" xml:"instance,omitempty"`
+	TraceID    string                 `json:"traceId,omitempty" xml:"traceId,omitempty"`
+	Extensions map[string]interface{} `json:"-" xml:"-"`
+}
+
+func (p ProblemDetails) MarshalJSON() ([]byte, error) {
+	members := make(map[string]interface{}, len(p.Extensions)+6)
+	for key, value := range p.Extensions {
+		members[key] = value
+	}
+
+	members["type"] = p.Type
+	members["title"] = p.Title
+	members["status"] = p.Status
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		members["instance"] = p.Instance
+	}
+	if p.TraceID != "" {
+		members["traceId"] = p.TraceID
+	}
+	return json.Marshal(members)
+}
+
+func (p ProblemDetails) Error() string {
+	if p.Detail == "" {
+		return p.Title
+	}
+	return p.Title + ": " + p.Detail
+}
+
+func (p ProblemDetails) WithExtension(key string, value interface{}) ProblemDetails {
+	extensions := make(map[string]interface{}, len(p.Extensions)+1)
+	for k, v := range p.Extensions {
+		extensions[k] = v
+	}
+	extensions[key] = value
+	p.Extensions = extensions
+	return p
+}
+
+// Response wraps the problem in a response carrying its status.
+func (p ProblemDetails) Response() Response {
+	return Response{
+		Status: p.Status,
+		Data:   p,
+	}
+}
+
+func NewProblem(status int, detail string) ProblemDetails {
+	return ProblemDetails{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+func Problem(status int, detail string) Response {
+	return NewProblem(status, detail).Response()
+}
+
+// ErrorMapper turns an error into a response. It reports false for errors
+// it does not handle.
+type ErrorMapper func(err error) (Response, bool)
+
+var (
+	errorMappersMu sync.RWMutex
+	errorMappers   []ErrorMapper
+)
+
+func init() {
+	RegisterErrorMapper(func(err error) (Response, bool) {
+		var invalid validation.Errors
+		if !errors.As(err, &invalid) {
+			return Response{}, false
+		}
+		return NewProblem(http.StatusBadRequest, "validation failed").WithExtension("errors", invalid).Response(), true
+	})
+	RegisterErrorMapper(func(err error) (Response, bool) {
+		var bindingErr *BindingError
+		if !errors.As(err, &bindingErr) {
+			return Response{}, false
+		}
+		return NewProblem(http.StatusBadRequest, "invalid request").WithExtension("errors", bindingErr.Errors).Response(), true
+	})
+	RegisterError(http.ErrMissingFile, http.StatusBadRequest)
+	RegisterError(http.ErrNotMultipart, http.StatusBadRequest)
+	RegisterError(ErrBodyTooLarge, http.StatusRequestEntityTooLarge)
+	RegisterError(ErrFileTooLarge, http.StatusRequestEntityTooLarge)
+	RegisterError(serialization.ErrUnsupportedMediaType, http.StatusUnsupportedMediaType)
+	RegisterError(serialization.ErrNotAcceptable, http.StatusNotAcceptable)
+	RegisterError(context.DeadlineExceeded, http.StatusGatewayTimeout)
+	RegisterError(validation.ErrUnknownRule, http.StatusInternalServerError)
+	RegisterErrorType[ProblemDetails](0)
+}
+
+// RegisterErrorMapper adds a mapper. Mappers registered later take
+// precedence, so applications can override the built-in mappings.
+func RegisterErrorMapper(mapper ErrorMapper) {
+	errorMappersMu.Lock()
+	defer errorMappersMu.Unlock()
+
+	errorMappers = append(errorMappers, mapper)
+}
+
+// DetailedError is implemented by errors that opt in to showing a message
+// to clients. Mapped errors otherwise get a generic detail, since their
+// message may carry internals such as hosts or queries.
+type DetailedError interface {
+	error
+	Detail() string
+}
+
+// RegisterError maps errors matching target with errors.Is to a problem
+// with the given status. The message of target is the detail, not the one
+// of the possibly wrapped error.
+func RegisterError(target error, status int) {
+	RegisterErrorMapper(func(err error) (Response, bool) {
+		if !errors.Is(err, target) {
+			return Response{}, false
+		}
+		return Problem(status, target.Error()), true
+	})
+}
+
+// RegisterErrorType maps errors matching T with errors.As. A zero status
+// keeps the status of T when it implements interface{ StatusCode() int }
+// or is a ProblemDetails, and falls back to 500. The detail comes from
+// DetailedError, or is the status text.
+func RegisterErrorType[T error](status int) {
+	RegisterErrorMapper(func(err error) (Response, bool) {
+		var target T
+		if !errors.As(err, &target) {
+			return Response{}, false
+		}
+
+		var value interface{} = target
+		if problem, ok := value.(ProblemDetails); ok && status == 0 {
+			return problem.Response(), true
+		}
+
+		code := status
+		if coded, ok := value.(interface{ StatusCode() int }); ok && code == 0 {
+			code = coded.StatusCode()
+		}
+
+		if code == 0 {
+			return Problem(http.StatusInternalServerError, ""), true
+		}
+
+		if detailed, ok := value.(DetailedError); ok {
+			return Problem(code, detailed.Detail()), true
+		}
+		return Problem(code, http.StatusText(code)), true
+	})
+}
+
+func mapError(err error) (Response, bool) {
+	errorMappersMu.RLock()
+	defer errorMappersMu.RUnlock()
+
+	for i := len(errorMappers) - 1; i >= 0; i-- {
+		if response, ok := errorMappers[i](err); ok {
+			return response, true
+		}
+	}
+	return Response{}, false
+}
+
+// FromError maps err through the registered mappers. Unknown errors become
+// a 500 problem without details, so internals are not leaked to clients.
+func FromError(err error) Response {
+	if response, ok := mapError(err); ok {
+		return response
+	}
+	return Problem(http.StatusInternalServerError, "")
+}
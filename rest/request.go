@@ -2,6 +2,7 @@ package rest
 
 import (
 	"context"
+	"net/http"
 	"net/url"
 )
 
@@ -13,7 +14,7 @@ func (r RequestMethod) String() string {
 
 func (r RequestMethod) Method() string {
 	switch r {
-	case LIST:
+	case LIST, WS:
 		return "GET"
 	default:
 		return string(r)
@@ -27,32 +28,55 @@ const (
 	PUT    RequestMethod = "PUT"
 	DELETE RequestMethod = "DELETE"
 	PATCH  RequestMethod = "PATCH"
+	WS     RequestMethod = "WS"
 )
 
 type Request struct {
 	Url           *url.URL
+	Host          string
 	Method        string
 	QueryParams   map[string][]string
 	PathParams    map[string]string
+	PathValues    map[string]interface{}
 	Headers       map[string][]string
 	Body          []byte
 	UserAgent     string
 	RemoteAddress string
+	body          *requestBody
 	ctx           context.Context
 }
 
+func NewRequest(r *http.Request) *Request {
+	return &Request{
+		Url:           r.URL,
+		Host:          r.Host,
+		Method:        r.Method,
+		QueryParams:   r.URL.Query(),
+		PathParams:    make(map[string]string),
+		Headers:       r.Header,
+		UserAgent:     r.UserAgent(),
+		RemoteAddress: r.RemoteAddr,
+		body:          newRequestBody(r.Body, r.ContentLength),
+		ctx:           r.Context(),
+	}
+}
+
 func (r *Request) Context() context.Context {
 	return r.ctx
 }
 
+// WithContext returns a shallow copy of the request with ctx. The body is
+// shared with the original request.
 func (r *Request) WithContext(ctx context.Context) *Request {
-	return &Request{
-		ctx:         ctx,
-		Url:         r.Url,
-		Method:      r.Method,
-		QueryParams: r.QueryParams,
-		PathParams:  r.PathParams,
-		Headers:     r.Headers,
-		Body:        r.Body,
+	copy := *r
+	copy.ctx = ctx
+	if copy.Body == nil && copy.body != nil {
+		copy.Body = copy.body.cached()
 	}
+	return &copy
+}
+
+func PathValue[T any](r *Request, name string) (T, bool) {
+	value, ok := r.PathValues[name].(T)
+	return value, ok
 }
@@ -1,8 +1,53 @@
 package rest
 
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Response is what actions return. The payload is taken from the first
+// non-empty field among Writer, Raw, Body and Data:
+//
+//   - Writer takes over the response once headers and cookies are set, and
+//     must write the status itself. HEAD requests get the status and
+//     headers only, without running it.
+//   - Raw is written as-is.
+//   - Body is streamed as-is and closed afterwards if it is an io.Closer.
+//   - Data is serialized. A nil Data produces an empty body.
 type Response struct {
-	Status int
-	Data   interface{}
+	Status  int
+	Data    interface{}
+	Headers http.Header
+	Cookies []*http.Cookie
+	Raw     []byte
+	Body    io.Reader
+	Writer  func(w http.ResponseWriter) error
+}
+
+func (r Response) WithHeader(key, value string) Response {
+	headers := r.Headers.Clone()
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	headers.Set(key, value)
+	r.Headers = headers
+	return r
+}
+
+func (r Response) WithCookie(cookie *http.Cookie) Response {
+	r.Cookies = append(r.Cookies[:len(r.Cookies):len(r.Cookies)], cookie)
+	return r
+}
+
+func (r Response) WithStatus(status int) Response {
+	r.Status = status
+	return r
 }
 
 func Ok[T any](data T) Response {
@@ -12,6 +57,78 @@ func Ok[T any](data T) Response {
 	}
 }
 
+func Created[T any](location string, data T) Response {
+	return Response{
+		Status:  201,
+		Data:    data,
+		Headers: http.Header{"Location": {location}},
+	}
+}
+
+func Accepted[T any](data T) Response {
+	return Response{
+		Status: 202,
+		Data:   data,
+	}
+}
+
+// Redirect answers with 302 Found. Use WithStatus for other redirect codes.
+func Redirect(location string) Response {
+	return Response{
+		Status:  302,
+		Headers: http.Header{"Location": {location}},
+	}
+}
+
+func Bytes(contentType string, data []byte) Response {
+	return Response{
+		Status:  200,
+		Raw:     data,
+		Headers: http.Header{"Content-Type": {contentType}},
+	}
+}
+
+func Stream(contentType string, body io.Reader) Response {
+	return Response{
+		Status:  200,
+		Body:    body,
+		Headers: http.Header{"Content-Type": {contentType}},
+	}
+}
+
+// File streams the file at path, guessing its Content-Type from the
+// extension.
+func File(path string) Response {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return NotFound()
+	}
+
+	if err != nil {
+		return Problem(http.StatusInternalServerError, "error opening file")
+	}
+
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		file.Close()
+		return NotFound()
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return Response{
+		Status: 200,
+		Body:   file,
+		Headers: http.Header{
+			"Content-Type":   {contentType},
+			"Content-Length": {strconv.FormatInt(info.Size(), 10)},
+		},
+	}
+}
+
 func Error[T any](data T) Response {
 	return Response{
 		Status: 500,
@@ -19,13 +136,21 @@ func Error[T any](data T) Response {
 	}
 }
 
-func NotFound() Response {
+func Forbidden() Response {
+	return Problem(http.StatusForbidden, "access to the resource is forbidden")
+}
+
+func Conflict[T any](data T) Response {
 	return Response{
-		Status: 404,
-		Data:   "resource not found",
+		Status: 409,
+		Data:   data,
 	}
 }
 
+func NotFound() Response {
+	return Problem(http.StatusNotFound, "resource not found")
+}
+
 func BadRequest[T any](data T) Response {
 	return Response{
 		Status: 400,
@@ -34,8 +159,38 @@ func BadRequest[T any](data T) Response {
 }
 
 func Unauthorized() Response {
+	return Problem(http.StatusUnauthorized, "authentication is required")
+}
+
+func MethodNotAllowed() Response {
+	return Problem(http.StatusMethodNotAllowed, "")
+}
+
+func NoContent() Response {
+	return Response{
+		Status: 204,
+	}
+}
+
+func NotAcceptable() Response {
+	return Problem(http.StatusNotAcceptable, "none of the accepted media types is supported")
+}
+
+func UnsupportedMediaType[T any](data T) Response {
 	return Response{
-		Status: 401,
-		Data:   "Unauthorized",
+		Status: 415,
+		Data:   data,
 	}
 }
+
+func PayloadTooLarge() Response {
+	return Problem(http.StatusRequestEntityTooLarge, "request body too large")
+}
+
+func PreconditionFailed() Response {
+	return Problem(http.StatusPreconditionFailed, "the resource does not match the request preconditions")
+}
+
+func PreconditionRequired() Response {
+	return Problem(http.StatusPreconditionRequired, "the request must be conditional")
+}
@@ -0,0 +1,231 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ramoncl001/comet/serialization"
+)
+
+// Event is a single Server-Sent Event. Data is written as-is when it is a
+// string or []byte and serialized as JSON otherwise.
+type Event struct {
+	ID    string
+	Event string
+	Data  interface{}
+	Retry time.Duration
+}
+
+var ErrStreamClosed = errors.New("event stream closed")
+
+type SSEOptions struct {
+	// Heartbeat is the interval of the comments sent to keep idle
+	// connections open through proxies. Zero disables heartbeats.
+	Heartbeat time.Duration
+}
+
+var DefaultSSEOptions = SSEOptions{
+	Heartbeat: 15 * time.Second,
+}
+
+// EventStream writes events to the client. It is safe for concurrent use.
+type EventStream struct {
+	mu         sync.Mutex
+	closed     bool
+	writer     io.Writer
+	controller *http.ResponseController
+}
+
+func (r *Request) LastEventID() string {
+	return http.Header(r.Headers).Get("Last-Event-ID")
+}
+
+// SSE answers with an event stream fed by handler. The stream ends when
+// handler returns; handler should stop once ctx is done, which happens
+// when the client disconnects.
+func SSE(req *Request, handler func(ctx context.Context, stream *EventStream) error) Response {
+	return SSEWithOptions(req, DefaultSSEOptions, handler)
+}
+
+// SSEChannel streams the events received from events until the channel is
+// closed or the client disconnects.
+func SSEChannel(req *Request, events <-chan Event) Response {
+	return SSE(req, func(ctx context.Context, stream *EventStream) error {
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case event, ok := <-events:
+				if !ok {
+					return nil
+				}
+
+				if err := stream.Send(event); err != nil {
+					return err
+				}
+			}
+		}
+	})
+}
+
+func SSEWithOptions(req *Request, opts SSEOptions, handler func(ctx context.Context, stream *EventStream) error) Response {
+	ctx := req.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	// Headers are part of the response rather than written by Writer, so
+	// HEAD requests, which skip Writer, get them too.
+	return Response{
+		Status: 200,
+		Headers: http.Header{
+			"Content-Type":      {"text/event-stream"},
+			"Cache-Control":     {"no-cache"},
+			"Connection":        {"keep-alive"},
+			"X-Accel-Buffering": {"no"},
+		},
+		Writer: func(w http.ResponseWriter) error {
+			w.WriteHeader(http.StatusOK)
+
+			stream := &EventStream{writer: w, controller: http.NewResponseController(w)}
+			if err := stream.flush(); err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithCancel(ctx)
+			var heartbeat sync.WaitGroup
+			if opts.Heartbeat > 0 {
+				heartbeat.Add(1)
+				go func() {
+					defer heartbeat.Done()
+					stream.heartbeat(ctx, cancel, opts.Heartbeat)
+				}()
+			}
+
+			err := handler(ctx, stream)
+			cancel()
+			heartbeat.Wait()
+			stream.close()
+
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return err
+		},
+	}
+}
+
+func (s *EventStream) Send(event Event) error {
+	var buffer bytes.Buffer
+	if event.ID != "" {
+		buffer.WriteString("id: " + singleLine(event.ID) + "\n")
+	}
+
+	if event.Event != "" {
+		buffer.WriteString("event: " + singleLine(event.Event) + "\n")
+	}
+
+	if event.Retry > 0 {
+		buffer.WriteString("retry: " + strconv.FormatInt(event.Retry.Milliseconds(), 10) + "\n")
+	}
+
+	data, err := eventData(event.Data)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range strings.Split(data, "\n") {
+		buffer.WriteString("data: " + strings.TrimSuffix(line, "\r") + "\n")
+	}
+	buffer.WriteByte('\n')
+
+	return s.write(buffer.Bytes())
+}
+
+// Comment sends a comment line, which clients ignore.
+func (s *EventStream) Comment(text string) error {
+	return s.write([]byte(": " + singleLine(text) + "\n\n"))
+}
+
+func (s *EventStream) write(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrStreamClosed
+	}
+
+	if _, err := s.writer.Write(data); err != nil {
+		return err
+	}
+	return s.flush()
+}
+
+// close stops writes from goroutines that outlive the handler, since the
+// response writer is no longer valid by then.
+func (s *EventStream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+}
+
+func (s *EventStream) flush() error {
+	err := s.controller.Flush()
+	if errors.Is(err, http.ErrNotSupported) {
+		return nil
+	}
+	return err
+}
+
+// heartbeat keeps the connection alive and cancels the stream as soon as
+// a write fails, so handlers notice disconnects even while idle.
+func (s *EventStream) heartbeat(ctx context.Context, cancel context.CancelFunc, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Comment("heartbeat"); err != nil {
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+func eventData(data interface{}) (string, error) {
+	switch value := data.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case []byte:
+		return string(value), nil
+	}
+
+	serializer, err := serialization.Lookup("application/json")
+	if err != nil {
+		return "", err
+	}
+
+	var buffer bytes.Buffer
+	if err := serializer.Serialize(&buffer, data); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+func singleLine(text string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(text)
+}
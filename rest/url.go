@@ -0,0 +1,12 @@
+package rest
+
+import "net/url"
+
+// URLGenerator builds paths from the templates registered by the router.
+// Routes are named "Controller.Action", e.g. "UsersController.GetByID", or
+// "pkg.Controller.Action" to tell apart controllers sharing a type name.
+// Names may be qualified with the prefix of the group the controller is
+// mapped in, e.g. "UsersController.GetByID@/v1", or "@/" for the root.
+type URLGenerator interface {
+	URLFor(name string, params map[string]string, query url.Values) (string, error)
+}
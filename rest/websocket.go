@@ -0,0 +1,538 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ramoncl001/comet/log"
+	"github.com/ramoncl001/comet/serialization"
+)
+
+type MessageType int
+
+const (
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+)
+
+const (
+	opContinuation = 0x0
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// Close codes defined by RFC 6455, section 7.4.1.
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseAbnormal        = 1006
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+)
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var ErrWebSocketClosed = errors.New("websocket closed")
+
+// CloseError is returned by reads once the peer closed the connection.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket closed with code %d: %s", e.Code, e.Reason)
+}
+
+type WebSocketOptions struct {
+	// ReadLimit is the maximum size of a message. Larger messages close
+	// the connection with CloseMessageTooBig. Zero takes the default limit
+	// and a negative value disables it.
+	ReadLimit int64
+	// PingInterval is how often pings are sent. A connection that stays
+	// silent for two intervals is considered dead. Zero disables pings.
+	PingInterval time.Duration
+	// WriteTimeout bounds every write.
+	WriteTimeout time.Duration
+	// Subprotocols lists the supported subprotocols in order of preference.
+	Subprotocols []string
+	// CheckOrigin accepts or rejects the handshake. By default the Origin
+	// header, when present, must match the Host.
+	CheckOrigin func(req *Request) bool
+}
+
+var DefaultWebSocketOptions = WebSocketOptions{
+	ReadLimit:    1 << 20,
+	PingInterval: 30 * time.Second,
+	WriteTimeout: 10 * time.Second,
+}
+
+// WithWebSocketOptions sets the options used by websocket actions in place
+// of DefaultWebSocketOptions.
+func WithWebSocketOptions(ctx context.Context, opts WebSocketOptions) context.Context {
+	return context.WithValue(ctx, webSocketOptionsKey, opts)
+}
+
+func WebSocketOptionsFromContext(ctx context.Context) (WebSocketOptions, bool) {
+	return contextValue[WebSocketOptions](ctx, webSocketOptionsKey)
+}
+
+// WebSocket is a server side connection. Reads must happen from a single
+// goroutine, writes are safe for concurrent use. Control frames are
+// handled while reading, so actions should keep reading for pings, pongs
+// and close frames to be processed.
+type WebSocket struct {
+	Subprotocol string
+
+	conn    net.Conn
+	reader  *bufio.Reader
+	opts    WebSocketOptions
+	ctx     context.Context
+	cancel  context.CancelFunc
+	writeMu sync.Mutex
+	closed  bool
+}
+
+func isWebSocketRequest(req *Request) bool {
+	headers := http.Header(req.Headers)
+	return headerContains(headers, "Connection", "upgrade") && headerContains(headers, "Upgrade", "websocket")
+}
+
+func headerContains(headers http.Header, name, token string) bool {
+	for _, value := range headers.Values(name) {
+		for _, item := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sameOrigin(req *Request) bool {
+	origin := http.Header(req.Headers).Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	parsed, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(parsed.Host, req.Host)
+}
+
+// Upgrade switches the request to the WebSocket protocol and runs handler
+// with the connection. The connection is closed when handler returns,
+// with CloseInternalError if it failed or panicked and CloseNormal
+// otherwise.
+func Upgrade(req *Request, opts WebSocketOptions, handler func(ws *WebSocket) error) Response {
+	headers := http.Header(req.Headers)
+	if req.Method != http.MethodGet || !isWebSocketRequest(req) {
+		return Problem(http.StatusUpgradeRequired, "websocket upgrade required").WithHeader("Upgrade", "websocket")
+	}
+
+	if headers.Get("Sec-WebSocket-Version") != "13" {
+		return Problem(http.StatusUpgradeRequired, "unsupported websocket version").WithHeader("Sec-WebSocket-Version", "13")
+	}
+
+	key := headers.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return Problem(http.StatusBadRequest, "invalid Sec-WebSocket-Key")
+	}
+
+	if opts.ReadLimit == 0 {
+		opts.ReadLimit = DefaultWebSocketOptions.ReadLimit
+	}
+
+	checkOrigin := opts.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+
+	if !checkOrigin(req) {
+		return Forbidden()
+	}
+
+	subprotocol := ""
+	for _, value := range headers.Values("Sec-WebSocket-Protocol") {
+		for _, offered := range strings.Split(value, ",") {
+			offered = strings.TrimSpace(offered)
+			if subprotocol == "" && slices.Contains(opts.Subprotocols, offered) {
+				subprotocol = offered
+			}
+		}
+	}
+
+	ctx := req.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return Response{
+		Status: http.StatusSwitchingProtocols,
+		Writer: func(w http.ResponseWriter) error {
+			conn, buffered, err := http.NewResponseController(w).Hijack()
+			if err != nil {
+				return err
+			}
+
+			hash := sha1.Sum([]byte(key + websocketGUID))
+			handshake := "HTTP/1.1 101 Switching Protocols\r\n" +
+				"Upgrade: websocket\r\n" +
+				"Connection: Upgrade\r\n" +
+				"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(hash[:]) + "\r\n"
+			if subprotocol != "" {
+				handshake += "Sec-WebSocket-Protocol: " + subprotocol + "\r\n"
+			}
+
+			if _, err := conn.Write([]byte(handshake + "\r\n")); err != nil {
+				conn.Close()
+				return err
+			}
+
+			ws := newWebSocket(ctx, conn, buffered.Reader, opts)
+			ws.Subprotocol = subprotocol
+			defer ws.cancel()
+
+			if opts.PingInterval > 0 {
+				go ws.keepAlive()
+			}
+
+			if err := runSocket(ws, handler); err != nil {
+				ws.Close(CloseInternalError, "internal error")
+				return err
+			}
+
+			ws.Close(CloseNormal, "")
+			return nil
+		},
+	}
+}
+
+// runSocket turns a panic in handler into an error. The handler runs after
+// the response left the middleware chain, so Recover cannot catch it.
+func runSocket(ws *WebSocket, handler func(ws *WebSocket) error) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.FromContext(ws.ctx).Error("panic error received from websocket", "error", recovered, "stack_trace", string(debug.Stack()))
+			err = fmt.Errorf("websocket handler panicked: %v", recovered)
+		}
+	}()
+	return handler(ws)
+}
+
+func newWebSocket(ctx context.Context, conn net.Conn, reader *bufio.Reader, opts WebSocketOptions) *WebSocket {
+	ctx, cancel := context.WithCancel(ctx)
+	ws := &WebSocket{conn: conn, reader: reader, opts: opts, ctx: ctx, cancel: cancel}
+	ws.extendDeadline()
+	return ws
+}
+
+// Context is done once the connection is closed.
+func (ws *WebSocket) Context() context.Context {
+	return ws.ctx
+}
+
+func (ws *WebSocket) keepAlive() {
+	ticker := time.NewTicker(ws.opts.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ws.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ws.Ping(nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (ws *WebSocket) extendDeadline() {
+	if ws.opts.PingInterval > 0 {
+		ws.conn.SetReadDeadline(time.Now().Add(2 * ws.opts.PingInterval))
+	}
+}
+
+// ReadMessage returns the next text or binary message. It fails with a
+// *CloseError once the peer closes the connection.
+func (ws *WebSocket) ReadMessage() (MessageType, []byte, error) {
+	var message bytes.Buffer
+	messageType := MessageType(0)
+
+	for {
+		fin, opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return 0, nil, ws.fail(err)
+		}
+
+		switch opcode {
+		case opPing:
+			if err := ws.writeFrame(opPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			return 0, nil, ws.peerClosed(payload)
+		case opContinuation:
+			if messageType == 0 {
+				return 0, nil, ws.fail(&CloseError{Code: CloseProtocolError, Reason: "unexpected continuation frame"})
+			}
+		case byte(TextMessage), byte(BinaryMessage):
+			if messageType != 0 {
+				return 0, nil, ws.fail(&CloseError{Code: CloseProtocolError, Reason: "expected continuation frame"})
+			}
+			messageType = MessageType(opcode)
+		default:
+			return 0, nil, ws.fail(&CloseError{Code: CloseProtocolError, Reason: "unknown opcode"})
+		}
+
+		if ws.opts.ReadLimit > 0 && int64(message.Len()+len(payload)) > ws.opts.ReadLimit {
+			return 0, nil, ws.fail(&CloseError{Code: CloseMessageTooBig, Reason: "message too big"})
+		}
+		message.Write(payload)
+
+		if fin {
+			if messageType == TextMessage && !utf8.Valid(message.Bytes()) {
+				return 0, nil, ws.fail(&CloseError{Code: CloseInvalidPayload, Reason: "invalid utf-8"})
+			}
+			return messageType, message.Bytes(), nil
+		}
+	}
+}
+
+func (ws *WebSocket) readFrame() (bool, byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(ws.reader, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	ws.extendDeadline()
+
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0f
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, &CloseError{Code: CloseProtocolError, Reason: "reserved bits set"}
+	}
+
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, &CloseError{Code: CloseProtocolError, Reason: "client frames must be masked"}
+	}
+
+	length := uint64(header[1] & 0x7f)
+	control := opcode&0x8 != 0
+	if control && (!fin || length > 125) {
+		return false, 0, nil, &CloseError{Code: CloseProtocolError, Reason: "invalid control frame"}
+	}
+
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(ws.reader, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(ws.reader, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+		if length > math.MaxInt64 {
+			return false, 0, nil, &CloseError{Code: CloseProtocolError, Reason: "invalid frame length"}
+		}
+	}
+
+	if ws.opts.ReadLimit > 0 && length > uint64(ws.opts.ReadLimit) {
+		return false, 0, nil, &CloseError{Code: CloseMessageTooBig, Reason: "message too big"}
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.reader, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	// The declared length is only trusted once it is within the read limit;
+	// without one the buffer grows with the bytes actually received.
+	var payload []byte
+	if ws.opts.ReadLimit > 0 {
+		payload = make([]byte, length)
+		if _, err := io.ReadFull(ws.reader, payload); err != nil {
+			return false, 0, nil, err
+		}
+	} else {
+		var buffer bytes.Buffer
+		if _, err := io.CopyN(&buffer, ws.reader, int64(length)); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return false, 0, nil, err
+		}
+		payload = buffer.Bytes()
+	}
+
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// fail closes the connection after a read error, telling the peer why
+// when the error is a protocol violation.
+func (ws *WebSocket) fail(err error) error {
+	var closeErr *CloseError
+	if errors.As(err, &closeErr) {
+		ws.Close(closeErr.Code, closeErr.Reason)
+		return err
+	}
+
+	ws.closeConn()
+	if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+		return &CloseError{Code: CloseAbnormal, Reason: "connection lost"}
+	}
+	return err
+}
+
+func (ws *WebSocket) peerClosed(payload []byte) error {
+	closeErr := &CloseError{Code: CloseNoStatus}
+	if len(payload) >= 2 {
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Reason = string(payload[2:])
+	}
+
+	ws.Close(CloseNormal, "")
+	return closeErr
+}
+
+func (ws *WebSocket) WriteMessage(messageType MessageType, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("invalid message type %d", messageType)
+	}
+	return ws.writeFrame(byte(messageType), data)
+}
+
+// ReadJSON reads the next message and decodes it into v using the JSON
+// serializer, so the configured JSON options apply.
+func (ws *WebSocket) ReadJSON(v interface{}) error {
+	_, data, err := ws.ReadMessage()
+	if err != nil {
+		return err
+	}
+
+	serializer, err := serialization.Lookup("application/json")
+	if err != nil {
+		return err
+	}
+	return serializer.Deserialize(bytes.NewReader(data), v)
+}
+
+func (ws *WebSocket) WriteJSON(v interface{}) error {
+	serializer, err := serialization.Lookup("application/json")
+	if err != nil {
+		return err
+	}
+
+	var buffer bytes.Buffer
+	if err := serializer.Serialize(&buffer, v); err != nil {
+		return err
+	}
+	return ws.WriteMessage(TextMessage, buffer.Bytes())
+}
+
+// Receive reads the next JSON message as a T.
+func Receive[T any](ws *WebSocket) (T, error) {
+	var message T
+	err := ws.ReadJSON(&message)
+	return message, err
+}
+
+func (ws *WebSocket) Ping(data []byte) error {
+	return ws.writeFrame(opPing, data)
+}
+
+// Close sends a close frame with the given code and closes the connection.
+// Calling it more than once is a no-op.
+func (ws *WebSocket) Close(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	if len(payload) > 125 {
+		payload = payload[:125]
+	}
+
+	err := ws.writeFrame(opClose, payload)
+	ws.closeConn()
+	if errors.Is(err, ErrWebSocketClosed) {
+		return nil
+	}
+	return err
+}
+
+func (ws *WebSocket) closeConn() {
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+
+	if !ws.closed {
+		ws.closed = true
+		ws.conn.Close()
+		ws.cancel()
+	}
+}
+
+func (ws *WebSocket) writeFrame(opcode byte, payload []byte) error {
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+
+	if ws.closed {
+		return ErrWebSocketClosed
+	}
+
+	header := make([]byte, 0, 10)
+	header = append(header, 0x80|opcode)
+	switch length := len(payload); {
+	case length <= 125:
+		header = append(header, byte(length))
+	case length <= 0xffff:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+
+	if ws.opts.WriteTimeout > 0 {
+		ws.conn.SetWriteDeadline(time.Now().Add(ws.opts.WriteTimeout))
+	}
+
+	if _, err := ws.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,327 @@
+package rest
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type frame struct {
+	fin     bool
+	opcode  byte
+	payload []byte
+}
+
+// peer is the client end of a net.Pipe. Frames written by the server are
+// collected in the background, since pipe writes block until read.
+type peer struct {
+	conn   net.Conn
+	frames chan frame
+}
+
+func pipeSocket(t *testing.T, opts WebSocketOptions) (*WebSocket, *peer) {
+	t.Helper()
+
+	server, client := net.Pipe()
+	ws := newWebSocket(context.Background(), server, bufio.NewReader(server), opts)
+	p := &peer{conn: client, frames: make(chan frame, 16)}
+	go p.collect()
+
+	t.Cleanup(func() {
+		ws.closeConn()
+		client.Close()
+	})
+	return ws, p
+}
+
+func (p *peer) collect() {
+	defer close(p.frames)
+
+	reader := bufio.NewReader(p.conn)
+	for {
+		var header [2]byte
+		if _, err := io.ReadFull(reader, header[:]); err != nil {
+			return
+		}
+
+		length := int(header[1] & 0x7f)
+		if length == 126 {
+			var extended [2]byte
+			if _, err := io.ReadFull(reader, extended[:]); err != nil {
+				return
+			}
+			length = int(binary.BigEndian.Uint16(extended[:]))
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			return
+		}
+		p.frames <- frame{fin: header[0]&0x80 != 0, opcode: header[0] & 0x0f, payload: payload}
+	}
+}
+
+// send writes client frames, masked unless told otherwise, without
+// waiting for the server to read them.
+func (p *peer) send(frames ...[]byte) {
+	go func() {
+		for _, data := range frames {
+			if _, err := p.conn.Write(data); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+func (p *peer) next(t *testing.T) frame {
+	t.Helper()
+
+	select {
+	case f, ok := <-p.frames:
+		if !ok {
+			t.Fatal("connection closed before a frame was received")
+		}
+		return f
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a frame")
+	}
+	return frame{}
+}
+
+func (p *peer) expectClose(t *testing.T, code int) {
+	t.Helper()
+
+	f := p.next(t)
+	if f.opcode != opClose || len(f.payload) < 2 {
+		t.Fatalf("got opcode %#x with %q, want a close frame", f.opcode, f.payload)
+	}
+
+	if got := int(binary.BigEndian.Uint16(f.payload)); got != code {
+		t.Fatalf("got close code %d, want %d", got, code)
+	}
+}
+
+func encodeFrame(fin bool, opcode byte, payload []byte, masked bool) []byte {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+
+	data := []byte{first}
+	maskBit := byte(0)
+	if masked {
+		maskBit = 0x80
+	}
+
+	switch {
+	case len(payload) <= 125:
+		data = append(data, maskBit|byte(len(payload)))
+	default:
+		data = append(data, maskBit|126)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(payload)))
+	}
+
+	if !masked {
+		return append(data, payload...)
+	}
+
+	mask := [4]byte{0x12, 0x34, 0x56, 0x78}
+	data = append(data, mask[:]...)
+	for i, b := range payload {
+		data = append(data, b^mask[i%4])
+	}
+	return data
+}
+
+func clientFrame(fin bool, opcode byte, payload string) []byte {
+	return encodeFrame(fin, opcode, []byte(payload), true)
+}
+
+func closePayload(code int, reason string) string {
+	return string(binary.BigEndian.AppendUint16(nil, uint16(code))) + reason
+}
+
+func TestWebSocketReadsMaskedMessages(t *testing.T) {
+	ws, p := pipeSocket(t, WebSocketOptions{})
+	p.send(
+		clientFrame(true, byte(TextMessage), "hello"),
+		clientFrame(true, byte(BinaryMessage), strings.Repeat("b", 300)),
+	)
+
+	messageType, data, err := ws.ReadMessage()
+	if err != nil || messageType != TextMessage || string(data) != "hello" {
+		t.Fatalf("got %d %q %v, want a text hello", messageType, data, err)
+	}
+
+	messageType, data, err = ws.ReadMessage()
+	if err != nil || messageType != BinaryMessage || len(data) != 300 {
+		t.Fatalf("got %d with %d bytes %v, want 300 binary bytes", messageType, len(data), err)
+	}
+}
+
+func TestWebSocketFragmentation(t *testing.T) {
+	ws, p := pipeSocket(t, WebSocketOptions{})
+	p.send(
+		clientFrame(false, byte(TextMessage), "hel"),
+		clientFrame(true, opPing, "ping"),
+		clientFrame(false, opContinuation, "lo "),
+		clientFrame(true, opContinuation, "world"),
+	)
+
+	messageType, data, err := ws.ReadMessage()
+	if err != nil || messageType != TextMessage || string(data) != "hello world" {
+		t.Fatalf("got %d %q %v, want a text hello world", messageType, data, err)
+	}
+
+	if pong := p.next(t); pong.opcode != opPong || string(pong.payload) != "ping" {
+		t.Fatalf("got opcode %#x with %q, want a pong echoing the ping", pong.opcode, pong.payload)
+	}
+}
+
+func TestWebSocketProtocolErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   WebSocketOptions
+		frames [][]byte
+		code   int
+	}{
+		{"unmasked", WebSocketOptions{}, [][]byte{encodeFrame(true, byte(TextMessage), []byte("hi"), false)}, CloseProtocolError},
+		{"reserved bits", WebSocketOptions{}, [][]byte{clientFrame(true, 0x40|byte(TextMessage), "hi")}, CloseProtocolError},
+		{"unknown opcode", WebSocketOptions{}, [][]byte{clientFrame(true, 0x3, "hi")}, CloseProtocolError},
+		{"fragmented control", WebSocketOptions{}, [][]byte{clientFrame(false, opPing, "hi")}, CloseProtocolError},
+		{"long control", WebSocketOptions{}, [][]byte{clientFrame(true, opPing, strings.Repeat("p", 126))}, CloseProtocolError},
+		{"orphan continuation", WebSocketOptions{}, [][]byte{clientFrame(true, opContinuation, "hi")}, CloseProtocolError},
+		{"interleaved message", WebSocketOptions{}, [][]byte{
+			clientFrame(false, byte(TextMessage), "a"),
+			clientFrame(true, byte(TextMessage), "b"),
+		}, CloseProtocolError},
+		{"invalid utf-8", WebSocketOptions{}, [][]byte{clientFrame(true, byte(TextMessage), "\xff\xfe")}, CloseInvalidPayload},
+		{"frame too big", WebSocketOptions{ReadLimit: 4}, [][]byte{clientFrame(true, byte(BinaryMessage), "12345")}, CloseMessageTooBig},
+		{"message too big", WebSocketOptions{ReadLimit: 4}, [][]byte{
+			clientFrame(false, byte(BinaryMessage), "123"),
+			clientFrame(true, opContinuation, "45"),
+		}, CloseMessageTooBig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, p := pipeSocket(t, tt.opts)
+			p.send(tt.frames...)
+
+			_, _, err := ws.ReadMessage()
+			var closeErr *CloseError
+			if !errors.As(err, &closeErr) || closeErr.Code != tt.code {
+				t.Fatalf("got %v, want a close error with code %d", err, tt.code)
+			}
+
+			p.expectClose(t, tt.code)
+			if err := ws.WriteMessage(TextMessage, []byte("late")); !errors.Is(err, ErrWebSocketClosed) {
+				t.Errorf("got %v writing after close, want ErrWebSocketClosed", err)
+			}
+		})
+	}
+}
+
+func TestWebSocketCloseHandshake(t *testing.T) {
+	ws, p := pipeSocket(t, WebSocketOptions{})
+	p.send(clientFrame(true, opClose, closePayload(CloseGoingAway, "bye")))
+
+	_, _, err := ws.ReadMessage()
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseGoingAway || closeErr.Reason != "bye" {
+		t.Fatalf("got %v, want the peer close code and reason", err)
+	}
+
+	p.expectClose(t, CloseNormal)
+	if _, ok := <-p.frames; ok {
+		t.Error("got a frame after the close frame")
+	}
+
+	select {
+	case <-ws.Context().Done():
+	default:
+		t.Error("context is not done after the close handshake")
+	}
+}
+
+func TestWebSocketServerClose(t *testing.T) {
+	ws, p := pipeSocket(t, WebSocketOptions{})
+
+	if err := ws.WriteMessage(TextMessage, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+
+	if f := p.next(t); f.opcode != byte(TextMessage) || !f.fin || string(f.payload) != "hi" {
+		t.Fatalf("got %+v, want a final text frame", f)
+	}
+
+	if err := ws.Close(ClosePolicyViolation, "nope"); err != nil {
+		t.Fatal(err)
+	}
+	p.expectClose(t, ClosePolicyViolation)
+
+	if err := ws.Close(CloseNormal, ""); err != nil {
+		t.Errorf("got %v closing twice, want nil", err)
+	}
+}
+
+func TestWebSocketHandlerPanic(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := Upgrade(NewRequest(r), WebSocketOptions{}, func(ws *WebSocket) error {
+			panic("boom")
+		})
+
+		if response.Writer == nil {
+			w.WriteHeader(response.Status)
+			return
+		}
+		response.Writer(w)
+	}))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+
+	handshake := "GET / HTTP/1.1\r\nHost: " + server.Listener.Addr().String() + "\r\n" +
+		"Connection: Upgrade\r\nUpgrade: websocket\r\nSec-WebSocket-Version: 13\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"
+	if _, err := conn.Write([]byte(handshake)); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("got status %d, want 101", response.StatusCode)
+	}
+
+	var header [2]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := make([]byte, header[1]&0x7f)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		t.Fatal(err)
+	}
+
+	if header[0]&0x0f != opClose || int(binary.BigEndian.Uint16(payload)) != CloseInternalError {
+		t.Fatalf("got opcode %#x with %q, want a close frame with code 1011", header[0]&0x0f, payload)
+	}
+}
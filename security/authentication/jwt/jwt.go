@@ -3,7 +3,7 @@ package jwt
 import (
 	"context"
 	"errors"
-	"fmt"
+	"net/http"
 	"strings"
 
 	"github.com/ramoncl001/comet/ioc"
@@ -61,12 +61,12 @@ func (sm *DefaultJwtSessionManager) Validate(req *rest.Request) (authentication.
 }
 
 func (sm *DefaultJwtSessionManager) GetUser(ctx context.Context) (security.ApplicationUser, error) {
-	id := ctx.Value("user_id")
-	if id == nil {
+	id, ok := rest.UserIDFromContext(ctx)
+	if !ok {
 		return nil, errors.New("session not started")
 	}
 
-	user := sm.userManager.GetByID(fmt.Sprintf("%v", id))
+	user := sm.userManager.GetByID(id)
 	if user == nil {
 		return nil, errors.New("user does not exists")
 	}
@@ -82,7 +82,7 @@ var DefaultJwtAuthenticationMiddleware = func(next rest.RequestHandler) rest.Req
 	return func(req *rest.Request) rest.Response {
 		manager, err := ioc.ResolveTransient[authentication.SessionManager](req.Context())
 		if err != nil {
-			return rest.Error("error resolving dependency")
+			return rest.Problem(http.StatusInternalServerError, "error resolving dependency")
 		}
 
 		claims, err := manager.Validate(req)
@@ -90,7 +90,7 @@ var DefaultJwtAuthenticationMiddleware = func(next rest.RequestHandler) rest.Req
 			return rest.Unauthorized()
 		}
 
-		req = req.WithContext(context.WithValue(req.Context(), "user_id", claims["sub"]))
+		req = req.WithContext(rest.WithClaims(req.Context(), claims))
 
 		return next(req)
 	}
@@ -7,7 +7,7 @@ import (
 	"github.com/ramoncl001/comet/security"
 )
 
-type Claims map[string]interface{}
+type Claims = rest.Claims
 
 type SessionManager interface {
 	GetToken(claims Claims) string
@@ -1,6 +1,8 @@
 package authorization
 
 import (
+	"net/http"
+
 	"github.com/ramoncl001/comet/ioc"
 	"github.com/ramoncl001/comet/rest"
 	"github.com/ramoncl001/comet/security/authentication"
@@ -10,7 +12,7 @@ var RequireRole = func(next rest.RequestHandler, value interface{}) rest.Request
 	return func(req *rest.Request) rest.Response {
 		sessionManager, err := ioc.ResolveSingleton[authentication.SessionManager](req.Context())
 		if err != nil {
-			return rest.Error("could not resolve session manager")
+			return rest.Problem(http.StatusInternalServerError, "could not resolve session manager")
 		}
 
 		claims, err := sessionManager.Validate(req)
@@ -0,0 +1,283 @@
+package serialization
+
+import (
+	"encoding"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSV handles slices of structs, with a header row built from the `csv`
+// tag, the `json` tag or the field name, in that order. A single struct is
+// written as one row and [][]string is written verbatim.
+type CSV struct{}
+
+type csvField struct {
+	name  string
+	index []int
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	textMarshalType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func (CSV) Serialize(w io.Writer, v interface{}) error {
+	writer := csv.NewWriter(w)
+
+	if records, ok := v.([][]string); ok {
+		return writer.WriteAll(records)
+	}
+
+	value := indirect(reflect.ValueOf(v))
+	if !value.IsValid() {
+		return fmt.Errorf("csv: cannot encode %T", v)
+	}
+
+	rows := []reflect.Value{value}
+	if isList(value) {
+		rows = make([]reflect.Value, value.Len())
+		for i := range rows {
+			rows[i] = value.Index(i)
+		}
+	}
+
+	elemType := value.Type()
+	if isList(value) {
+		elemType = elemType.Elem()
+	}
+	for elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+
+	if elemType.Kind() != reflect.Struct {
+		return fmt.Errorf("csv: cannot encode %T", v)
+	}
+
+	fields := csvFields(elemType, nil)
+	header := make([]string, len(fields))
+	for i, field := range fields {
+		header[i] = field.name
+	}
+
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(fields))
+	for _, row := range rows {
+		row = indirect(row)
+		if !row.IsValid() {
+			continue
+		}
+
+		for i, field := range fields {
+			record[i] = formatCSV(row.FieldByIndex(field.index))
+		}
+
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// Deserialize reads a header row followed by records into a pointer to a
+// slice of structs, or into *[][]string. Unknown columns are ignored.
+func (CSV) Deserialize(r io.Reader, v interface{}) error {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return err
+	}
+
+	if target, ok := v.(*[][]string); ok {
+		*target = records
+		return nil
+	}
+
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Ptr || target.IsNil() || target.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("csv: cannot decode into %T", v)
+	}
+
+	slice := target.Elem()
+	elemType := slice.Type().Elem()
+	structType := elemType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+
+	if structType.Kind() != reflect.Struct {
+		return fmt.Errorf("csv: cannot decode into %T", v)
+	}
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	byName := make(map[string]csvField)
+	for _, field := range csvFields(structType, nil) {
+		byName[field.name] = field
+	}
+
+	columns := make([]*csvField, len(records[0]))
+	for i, name := range records[0] {
+		if field, ok := byName[strings.TrimSpace(name)]; ok {
+			columns[i] = &field
+		}
+	}
+
+	for line, record := range records[1:] {
+		item := reflect.New(structType).Elem()
+		for i, raw := range record {
+			if i >= len(columns) || columns[i] == nil {
+				continue
+			}
+
+			if err := parseCSV(item.FieldByIndex(columns[i].index), raw); err != nil {
+				return fmt.Errorf("csv: line %d, column %q: %w", line+2, columns[i].name, err)
+			}
+		}
+
+		if elemType.Kind() == reflect.Ptr {
+			item = item.Addr()
+		}
+		slice.Set(reflect.Append(slice, item))
+	}
+	return nil
+}
+
+func csvFields(typ reflect.Type, index []int) []csvField {
+	fields := make([]csvField, 0, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		fieldIndex := append(index[:len(index):len(index)], i)
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			fields = append(fields, csvFields(field.Type, fieldIndex)...)
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("csv"); ok {
+			name, _, _ = strings.Cut(tag, ",")
+		} else if tag, ok := field.Tag.Lookup("json"); ok {
+			if tagName, _, _ := strings.Cut(tag, ","); tagName != "" {
+				name = tagName
+			}
+		}
+
+		if name == "-" {
+			continue
+		}
+		fields = append(fields, csvField{name: name, index: fieldIndex})
+	}
+	return fields
+}
+
+func formatCSV(value reflect.Value) string {
+	value = indirect(value)
+	if !value.IsValid() {
+		return ""
+	}
+
+	if value.Type() == timeType {
+		return value.Interface().(time.Time).Format(time.RFC3339Nano)
+	}
+
+	if value.Type().Implements(textMarshalType) {
+		text, err := value.Interface().(encoding.TextMarshaler).MarshalText()
+		if err == nil {
+			return string(text)
+		}
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		return value.String()
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if number, err := formatNumber(value); err == nil {
+			return number
+		}
+	}
+	return fmt.Sprint(value.Interface())
+}
+
+func parseCSV(field reflect.Value, raw string) error {
+	if field.Kind() == reflect.Ptr {
+		if raw == "" {
+			return nil
+		}
+
+		target := reflect.New(field.Type().Elem())
+		if err := parseCSV(target.Elem(), raw); err != nil {
+			return err
+		}
+		field.Set(target)
+		return nil
+	}
+
+	if field.Type() == timeType {
+		t, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	if field.Addr().Type().Implements(textUnmarshalType) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(raw))
+	}
+
+	if raw == "" && field.Kind() != reflect.String {
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(v)
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(raw, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(v)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package serialization
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type csvRow struct {
+	ID      int       `csv:"id"`
+	Name    string    `json:"name"`
+	Level   level     `csv:"level"`
+	Ratio   float32   `csv:"ratio"`
+	Big     uint64    `csv:"big"`
+	Active  bool      `csv:"active"`
+	Created time.Time `csv:"created"`
+	Note    *string   `csv:"note"`
+	Skipped string    `csv:"-"`
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	note := "a, \"quoted\"\nnote"
+	rows := []csvRow{
+		{ID: 1, Name: "first", Level: 3, Ratio: 0.1, Big: 1<<64 - 1, Active: true,
+			Created: time.Date(2024, 5, 1, 10, 0, 0, 5, time.UTC), Note: &note},
+		{ID: -2, Name: "", Created: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)},
+	}
+
+	var buffer bytes.Buffer
+	if err := (CSV{}).Serialize(&buffer, rows); err != nil {
+		t.Fatalf("serialize: %v", err)
+	}
+
+	header, _, _ := strings.Cut(buffer.String(), "\n")
+	if header != "id,name,level,ratio,big,active,created,note" {
+		t.Errorf("got header %q", header)
+	}
+
+	var decoded []csvRow
+	if err := (CSV{}).Deserialize(&buffer, &decoded); err != nil {
+		t.Fatalf("deserialize: %v", err)
+	}
+
+	if !reflect.DeepEqual(decoded, rows) {
+		t.Errorf("got  %+v\nwant %+v", decoded, rows)
+	}
+}
+
+func TestCSVPointerRows(t *testing.T) {
+	rows := []*csvRow{{ID: 1, Name: "a", Created: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}}
+
+	var buffer bytes.Buffer
+	if err := (CSV{}).Serialize(&buffer, rows); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded []*csvRow
+	if err := (CSV{}).Deserialize(&buffer, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(decoded, rows) {
+		t.Errorf("got %+v, want %+v", decoded[0], rows[0])
+	}
+}
+
+func TestCSVRecords(t *testing.T) {
+	records := [][]string{{"a", "b"}, {"1", "x,y"}}
+
+	var buffer bytes.Buffer
+	if err := (CSV{}).Serialize(&buffer, records); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded [][]string
+	if err := (CSV{}).Deserialize(&buffer, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(decoded, records) {
+		t.Errorf("got %q, want %q", decoded, records)
+	}
+}
+
+func TestCSVDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"bad number", "id\nx\n"},
+		{"overflow", "level\n99999999999999999999\n"},
+		{"bad time", "created\nyesterday\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rows []csvRow
+			if err := (CSV{}).Deserialize(strings.NewReader(tt.input), &rows); err == nil {
+				t.Errorf("expected an error for %q", tt.input)
+			}
+		})
+	}
+}
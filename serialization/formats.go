@@ -0,0 +1,179 @@
+package serialization
+
+import (
+	"bufio"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+func init() {
+	Register("application/json", JSON{})
+	Register("application/xml", XML{})
+	Register("text/xml", XML{})
+	Register("text/csv", CSV{})
+	Register("application/x-ndjson", NDJSON{})
+	Register("application/msgpack", MessagePack{})
+	Register("application/x-msgpack", MessagePack{})
+	Register("text/plain", Text{})
+}
+
+// XML wraps slices in an <items> root element so that the output is a
+// well-formed document.
+type XML struct{}
+
+func (XML) Serialize(w io.Writer, v interface{}) error {
+	encoder := xml.NewEncoder(w)
+	value := indirect(reflect.ValueOf(v))
+	if !isList(value) {
+		return encoder.Encode(v)
+	}
+
+	root := xml.StartElement{Name: xml.Name{Local: "items"}}
+	if err := encoder.EncodeToken(root); err != nil {
+		return err
+	}
+
+	for i := 0; i < value.Len(); i++ {
+		if err := encoder.Encode(value.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+
+	if err := encoder.EncodeToken(root.End()); err != nil {
+		return err
+	}
+	return encoder.Flush()
+}
+
+func (XML) Deserialize(r io.Reader, v interface{}) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+// NDJSON writes every element of a slice as one JSON document per line.
+// Other values are written as a single line. The JSON options apply, except
+// for indentation.
+type NDJSON struct{}
+
+func (NDJSON) Serialize(w io.Writer, v interface{}) error {
+	codec := currentJSON()
+	value := indirect(reflect.ValueOf(v))
+	if !isList(value) {
+		return writeLine(w, codec, v)
+	}
+
+	for i := 0; i < value.Len(); i++ {
+		if err := writeLine(w, codec, value.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeLine(w io.Writer, codec *jsonCodec, v interface{}) error {
+	data, err := codec.marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Deserialize appends every line to v when it points to a slice, and
+// decodes a single document otherwise.
+func (NDJSON) Deserialize(r io.Reader, v interface{}) error {
+	codec := currentJSON()
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return fmt.Errorf("ndjson: cannot decode into %T", v)
+	}
+
+	slice := target.Elem()
+	for {
+		var line json.RawMessage
+		err := decoder.Decode(&line)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if slice.Kind() != reflect.Slice {
+			return codec.unmarshal(line, v)
+		}
+
+		item := reflect.New(slice.Type().Elem())
+		if err := codec.unmarshal(line, item.Interface()); err != nil {
+			return err
+		}
+		slice.Set(reflect.Append(slice, item.Elem()))
+	}
+}
+
+type Text struct{}
+
+func (Text) Serialize(w io.Writer, v interface{}) error {
+	switch value := v.(type) {
+	case string:
+		_, err := io.WriteString(w, value)
+		return err
+	case []byte:
+		_, err := w.Write(value)
+		return err
+	case encoding.TextMarshaler:
+		text, err := value.MarshalText()
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(text)
+		return err
+	case error:
+		_, err := io.WriteString(w, value.Error())
+		return err
+	}
+
+	_, err := fmt.Fprint(w, v)
+	return err
+}
+
+func (Text) Deserialize(r io.Reader, v interface{}) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	switch target := v.(type) {
+	case *string:
+		*target = string(data)
+	case *[]byte:
+		*target = data
+	case encoding.TextUnmarshaler:
+		return target.UnmarshalText(data)
+	default:
+		return fmt.Errorf("text: cannot decode into %T", v)
+	}
+	return nil
+}
+
+func indirect(value reflect.Value) reflect.Value {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return reflect.Value{}
+		}
+		value = value.Elem()
+	}
+	return value
+}
+
+func isList(value reflect.Value) bool {
+	kind := value.Kind()
+	return (kind == reflect.Slice || kind == reflect.Array) && value.Type().Elem().Kind() != reflect.Uint8
+}
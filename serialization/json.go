@@ -0,0 +1,1031 @@
+package serialization
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// JSONEngine performs the actual encoding, so encoding/json can be swapped
+// for a faster drop-in implementation.
+type JSONEngine interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// NamingPolicy derives the JSON name of fields whose json tag has no name.
+type NamingPolicy func(field string) string
+
+type TimeFormat int
+
+const (
+	TimeRFC3339 TimeFormat = iota
+	TimeUnix
+	TimeUnixMilli
+)
+
+type JSONOptions struct {
+	// Naming renames fields without an explicit json tag name. Input is
+	// matched using the same names.
+	Naming NamingPolicy
+	// OmitNulls drops struct fields holding nil pointers, slices, maps or
+	// interfaces instead of writing null.
+	OmitNulls bool
+	// TimeFormat controls how time.Time values are written. Input accepts
+	// RFC 3339 strings as well as the configured unix format.
+	TimeFormat TimeFormat
+	// NumbersAsStrings writes numbers as JSON strings and accepts quoted
+	// numbers on input, which keeps 64-bit integers safe in JavaScript.
+	NumbersAsStrings bool
+	// Indent pretty prints output when set, e.g. "  " during development.
+	Indent string
+	// DisallowUnknownFields rejects input objects with fields that do not
+	// map to the target struct.
+	DisallowUnknownFields bool
+	// Engine defaults to encoding/json.
+	Engine JSONEngine
+}
+
+type stdJSON struct{}
+
+func (stdJSON) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (stdJSON) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// jsonCodec pairs options with the struct layouts computed for them, so
+// layouts are never shared between options with different naming.
+type jsonCodec struct {
+	opts   JSONOptions
+	fields sync.Map
+}
+
+var (
+	jsonMu      sync.RWMutex
+	globalCodec = &jsonCodec{}
+	jsonCodecs  sync.Map
+)
+
+// ConfigureJSON sets the options used by the JSON serializer, as well as
+// by NDJSON and the field names used by MessagePack.
+func ConfigureJSON(opts JSONOptions) {
+	jsonMu.Lock()
+	defer jsonMu.Unlock()
+
+	globalCodec = &jsonCodec{opts: opts}
+}
+
+func currentJSON() *jsonCodec {
+	jsonMu.RLock()
+	defer jsonMu.RUnlock()
+
+	return globalCodec
+}
+
+// JSON uses Options when set and the options given to ConfigureJSON
+// otherwise. Options are read on first use and must not change afterwards.
+type JSON struct {
+	Options *JSONOptions
+}
+
+func (j JSON) codec() *jsonCodec {
+	if j.Options == nil {
+		return currentJSON()
+	}
+
+	if codec, ok := jsonCodecs.Load(j.Options); ok {
+		return codec.(*jsonCodec)
+	}
+
+	codec, _ := jsonCodecs.LoadOrStore(j.Options, &jsonCodec{opts: *j.Options})
+	return codec.(*jsonCodec)
+}
+
+func (j JSON) Serialize(w io.Writer, v interface{}) error {
+	codec := j.codec()
+	data, err := codec.marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if codec.opts.Indent != "" {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, data, "", codec.opts.Indent); err != nil {
+			return err
+		}
+		data = indented.Bytes()
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+func (j JSON) Deserialize(r io.Reader, v interface{}) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return j.codec().unmarshal(data, v)
+}
+
+func (o JSONOptions) engine() JSONEngine {
+	if o.Engine != nil {
+		return o.Engine
+	}
+	return stdJSON{}
+}
+
+// transforms reports whether the options change the data model, as
+// opposed to formatting or the engine only.
+func (o JSONOptions) transforms() bool {
+	return o.Naming != nil || o.OmitNulls || o.TimeFormat != TimeRFC3339 || o.NumbersAsStrings
+}
+
+func (c *jsonCodec) marshal(v interface{}) ([]byte, error) {
+	if !c.opts.transforms() {
+		return c.opts.engine().Marshal(v)
+	}
+
+	encoder := &jsonEncoder{codec: c, engine: c.opts.engine()}
+	if err := encoder.encode(reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return encoder.buffer.Bytes(), nil
+}
+
+// unmarshal parses the input once and assigns it to v, renaming fields and
+// converting times and quoted numbers on the way.
+func (c *jsonCodec) unmarshal(data []byte, v interface{}) error {
+	if !c.opts.transforms() && !c.opts.DisallowUnknownFields {
+		return c.opts.engine().Unmarshal(data, v)
+	}
+
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return &json.InvalidUnmarshalError{Type: reflect.TypeOf(v)}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return err
+	}
+
+	if decoder.More() {
+		return errors.New("json: invalid character after top-level value")
+	}
+	return c.assign(target.Elem(), generic, "")
+}
+
+type jsonField struct {
+	name      string
+	goName    string
+	index     []int
+	omitEmpty bool
+	omitZero  bool
+	quoted    bool
+	tagged    bool
+}
+
+var jsonMarshalType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// jsonFields lists the fields of a struct in the order encoding/json
+// would write them, flattening embedded structs with the same rules for
+// conflicting names.
+func (c *jsonCodec) jsonFields(typ reflect.Type) []jsonField {
+	if cached, ok := c.fields.Load(typ); ok {
+		return cached.([]jsonField)
+	}
+
+	fields := dominantFields(c.opts.collectFields(typ, nil, nil))
+	c.fields.Store(typ, fields)
+	return fields
+}
+
+// collectFields walks the fields depth first. Types already being walked
+// are skipped, so recursive embedding terminates.
+func (o JSONOptions) collectFields(typ reflect.Type, index []int, walking []reflect.Type) []jsonField {
+	walking = append(walking, typ)
+	fields := make([]jsonField, 0, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		fieldIndex := append(index[:len(index):len(index)], i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, flags, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				if !slices.Contains(walking, embedded) {
+					fields = append(fields, o.collectFields(embedded, fieldIndex, walking)...)
+				}
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		tagged := name != ""
+		goName := name
+		if name == "" {
+			goName = field.Name
+			name = field.Name
+			if o.Naming != nil {
+				name = o.Naming(field.Name)
+			}
+		}
+
+		options := strings.Split(flags, ",")
+		fields = append(fields, jsonField{
+			name:      name,
+			goName:    goName,
+			index:     fieldIndex,
+			omitEmpty: slices.Contains(options, "omitempty"),
+			omitZero:  slices.Contains(options, "omitzero"),
+			quoted:    slices.Contains(options, "string") && quotable(field.Type),
+			tagged:    tagged,
+		})
+	}
+	return fields
+}
+
+// dominantFields resolves fields sharing a name as encoding/json does: the
+// shallowest one wins, then the only tagged one among equally deep fields.
+// Otherwise the conflicting fields are all dropped.
+func dominantFields(fields []jsonField) []jsonField {
+	result := make([]jsonField, 0, len(fields))
+	for _, field := range fields {
+		depth, shallowest, tagged := len(field.index), 0, 0
+		for _, other := range fields {
+			if other.name == field.name && len(other.index) < depth {
+				depth = len(other.index)
+			}
+		}
+
+		for _, other := range fields {
+			if other.name != field.name || len(other.index) != depth {
+				continue
+			}
+
+			shallowest++
+			if other.tagged {
+				tagged++
+			}
+		}
+
+		if len(field.index) != depth {
+			continue
+		}
+
+		if shallowest == 1 || tagged == 1 && field.tagged {
+			result = append(result, field)
+		}
+	}
+	return result
+}
+
+type jsonEncoder struct {
+	codec  *jsonCodec
+	engine JSONEngine
+	buffer bytes.Buffer
+}
+
+func (e *jsonEncoder) encode(value reflect.Value) error {
+	if !value.IsValid() {
+		e.buffer.WriteString("null")
+		return nil
+	}
+
+	if value.Type() == timeType {
+		return e.encodeTime(value.Interface().(time.Time))
+	}
+
+	if marshaler, ok := marshalerOf(value); ok {
+		return e.leaf(marshaler)
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			e.buffer.WriteString("null")
+			return nil
+		}
+		return e.encode(value.Elem())
+	case reflect.Struct:
+		return e.encodeStruct(value)
+	case reflect.Map:
+		return e.encodeMap(value)
+	case reflect.Slice:
+		if value.IsNil() {
+			e.buffer.WriteString("null")
+			return nil
+		}
+
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			return e.leaf(value.Interface())
+		}
+		return e.encodeList(value)
+	case reflect.Array:
+		return e.encodeList(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		number, err := formatNumber(value)
+		if err != nil {
+			return err
+		}
+
+		if e.codec.opts.NumbersAsStrings {
+			return e.leaf(number)
+		}
+		e.buffer.WriteString(number)
+		return nil
+	}
+	return e.leaf(value.Interface())
+}
+
+func (e *jsonEncoder) leaf(v interface{}) error {
+	data, err := e.engine.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	e.buffer.Write(data)
+	return nil
+}
+
+func (e *jsonEncoder) encodeTime(t time.Time) error {
+	switch e.codec.opts.TimeFormat {
+	case TimeUnix:
+		e.buffer.WriteString(strconv.FormatInt(t.Unix(), 10))
+	case TimeUnixMilli:
+		e.buffer.WriteString(strconv.FormatInt(t.UnixMilli(), 10))
+	default:
+		return e.leaf(t)
+	}
+	return nil
+}
+
+func (e *jsonEncoder) encodeStruct(value reflect.Value) error {
+	e.buffer.WriteByte('{')
+	first := true
+	for _, field := range e.codec.jsonFields(value.Type()) {
+		fieldValue, ok := fieldByIndex(value, field.index)
+		if !ok {
+			continue
+		}
+
+		if field.omitEmpty && isEmpty(fieldValue) || field.omitZero && isZero(fieldValue) || e.codec.opts.OmitNulls && isNull(fieldValue) {
+			continue
+		}
+
+		if !first {
+			e.buffer.WriteByte(',')
+		}
+		first = false
+
+		if err := e.leaf(field.name); err != nil {
+			return err
+		}
+		e.buffer.WriteByte(':')
+
+		if field.quoted && !isNull(fieldValue) && !(e.codec.opts.NumbersAsStrings && isNumber(fieldValue)) {
+			data, err := e.engine.Marshal(fieldValue.Interface())
+			if err != nil {
+				return err
+			}
+
+			if err := e.leaf(string(data)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := e.encode(fieldValue); err != nil {
+			return err
+		}
+	}
+	e.buffer.WriteByte('}')
+	return nil
+}
+
+func (e *jsonEncoder) encodeMap(value reflect.Value) error {
+	if value.IsNil() {
+		e.buffer.WriteString("null")
+		return nil
+	}
+
+	keys := make([]string, 0, value.Len())
+	values := make(map[string]reflect.Value, value.Len())
+	iter := value.MapRange()
+	for iter.Next() {
+		key, err := mapKey(iter.Key())
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		values[key] = iter.Value()
+	}
+	slices.Sort(keys)
+
+	e.buffer.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			e.buffer.WriteByte(',')
+		}
+
+		if err := e.leaf(key); err != nil {
+			return err
+		}
+		e.buffer.WriteByte(':')
+
+		if err := e.encode(values[key]); err != nil {
+			return err
+		}
+	}
+	e.buffer.WriteByte('}')
+	return nil
+}
+
+func (e *jsonEncoder) encodeList(value reflect.Value) error {
+	e.buffer.WriteByte('[')
+	for i := 0; i < value.Len(); i++ {
+		if i > 0 {
+			e.buffer.WriteByte(',')
+		}
+
+		if err := e.encode(value.Index(i)); err != nil {
+			return err
+		}
+	}
+	e.buffer.WriteByte(']')
+	return nil
+}
+
+// assign stores a value parsed with UseNumber into dst, following the
+// rules of encoding/json. Type mismatches fail with *json.UnmarshalTypeError.
+// It also takes the native numbers and []byte decoded from MessagePack.
+func (c *jsonCodec) assign(dst reflect.Value, v interface{}, path string) error {
+	if v == nil {
+		switch dst.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+			dst.Set(reflect.Zero(dst.Type()))
+		}
+		return nil
+	}
+
+	if dst.Kind() == reflect.Ptr {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return c.assign(dst.Elem(), v, path)
+	}
+
+	if dst.Type() == timeType {
+		if number, ok := numberText(v); ok {
+			converted, err := unixTime(json.Number(number), c.opts.TimeFormat)
+			if err != nil {
+				return err
+			}
+			v = converted
+		}
+	}
+
+	if dst.CanAddr() {
+		pointer := dst.Addr()
+		if unmarshaler, ok := pointer.Interface().(json.Unmarshaler); ok {
+			data, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			return unmarshaler.UnmarshalJSON(data)
+		}
+
+		if unmarshaler, ok := pointer.Interface().(encoding.TextUnmarshaler); ok {
+			if s, ok := v.(string); ok {
+				return unmarshaler.UnmarshalText([]byte(s))
+			}
+			return mismatch(v, dst.Type(), path)
+		}
+	}
+
+	switch dst.Kind() {
+	case reflect.Interface:
+		if dst.NumMethod() > 0 {
+			return mismatch(v, dst.Type(), path)
+		}
+		dst.Set(reflect.ValueOf(plain(v)))
+		return nil
+	case reflect.Struct:
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			return mismatch(v, dst.Type(), path)
+		}
+		return c.assignStruct(dst, object, path)
+	case reflect.Map:
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			return mismatch(v, dst.Type(), path)
+		}
+		return c.assignMap(dst, object, path)
+	case reflect.Slice:
+		if dst.Type().Elem().Kind() == reflect.Uint8 {
+			switch data := v.(type) {
+			case []byte:
+				dst.SetBytes(data)
+				return nil
+			case string:
+				decoded, err := base64.StdEncoding.DecodeString(data)
+				if err != nil {
+					return err
+				}
+				dst.SetBytes(decoded)
+				return nil
+			}
+		}
+
+		list, ok := v.([]interface{})
+		if !ok {
+			return mismatch(v, dst.Type(), path)
+		}
+
+		dst.Set(reflect.MakeSlice(dst.Type(), len(list), len(list)))
+		for i, item := range list {
+			if err := c.assign(dst.Index(i), item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Array:
+		list, ok := v.([]interface{})
+		if !ok {
+			return mismatch(v, dst.Type(), path)
+		}
+
+		for i := 0; i < dst.Len(); i++ {
+			if i >= len(list) {
+				dst.Index(i).Set(reflect.Zero(dst.Type().Elem()))
+				continue
+			}
+
+			if err := c.assign(dst.Index(i), list[i], fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.String:
+		switch s := v.(type) {
+		case string:
+			dst.SetString(s)
+		case []byte:
+			dst.SetString(string(s))
+		default:
+			return mismatch(v, dst.Type(), path)
+		}
+		return nil
+	case reflect.Bool:
+		b, ok := v.(bool)
+		if !ok {
+			return mismatch(v, dst.Type(), path)
+		}
+		dst.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		number, ok := numberText(v)
+		if s, quoted := v.(string); quoted && c.opts.NumbersAsStrings {
+			number, ok = s, true
+		}
+
+		if !ok {
+			return mismatch(v, dst.Type(), path)
+		}
+
+		if !setNumber(dst, number) {
+			return &json.UnmarshalTypeError{Value: "number " + number, Type: dst.Type(), Field: path}
+		}
+		return nil
+	}
+	return mismatch(v, dst.Type(), path)
+}
+
+func (c *jsonCodec) assignStruct(dst reflect.Value, object map[string]interface{}, path string) error {
+	fields := c.jsonFields(dst.Type())
+	for key, item := range object {
+		field, ok := matchField(fields, key)
+		if !ok {
+			if c.opts.DisallowUnknownFields {
+				return fmt.Errorf("json: unknown field %q", joinPath(path, key))
+			}
+			continue
+		}
+
+		fieldValue, ok := settableField(dst, field.index)
+		if !ok {
+			continue
+		}
+
+		if s, ok := item.(string); ok && field.quoted {
+			decoder := json.NewDecoder(strings.NewReader(s))
+			decoder.UseNumber()
+			if err := decoder.Decode(&item); err != nil {
+				return fmt.Errorf("json: invalid use of ,string struct tag, trying to unmarshal %q into %s", s, fieldValue.Type())
+			}
+		}
+
+		if err := c.assign(fieldValue, item, joinPath(path, key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *jsonCodec) assignMap(dst reflect.Value, object map[string]interface{}, path string) error {
+	typ := dst.Type()
+	if dst.IsNil() {
+		dst.Set(reflect.MakeMapWithSize(typ, len(object)))
+	}
+
+	for key, item := range object {
+		keyValue := reflect.New(typ.Key()).Elem()
+		switch {
+		case reflect.PointerTo(typ.Key()).Implements(textUnmarshalType):
+			if err := keyValue.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(key)); err != nil {
+				return err
+			}
+		case typ.Key().Kind() == reflect.String:
+			keyValue.SetString(key)
+		default:
+			if !setNumber(keyValue, key) {
+				return &json.UnmarshalTypeError{Value: "number " + key, Type: typ.Key(), Field: joinPath(path, key)}
+			}
+		}
+
+		elem := reflect.New(typ.Elem()).Elem()
+		if err := c.assign(elem, item, joinPath(path, key)); err != nil {
+			return err
+		}
+		dst.SetMapIndex(keyValue, elem)
+	}
+	return nil
+}
+
+// matchField prefers an exact match and falls back to a case-insensitive
+// one, like encoding/json does.
+func matchField(fields []jsonField, key string) (jsonField, bool) {
+	for _, field := range fields {
+		if field.name == key {
+			return field, true
+		}
+	}
+
+	for _, field := range fields {
+		if strings.EqualFold(field.name, key) {
+			return field, true
+		}
+	}
+	return jsonField{}, false
+}
+
+// settableField is fieldByIndex for decoding: nil embedded pointers are
+// allocated, unless they are unexported.
+func settableField(value reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				if !value.CanSet() {
+					return reflect.Value{}, false
+				}
+				value.Set(reflect.New(value.Type().Elem()))
+			}
+			value = value.Elem()
+		}
+		value = value.Field(x)
+	}
+	return value, value.CanSet()
+}
+
+// setNumber parses number into an integer or float value, reporting false
+// on syntax errors and overflows.
+func setNumber(dst reflect.Value, number string) bool {
+	switch dst.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(number, 10, 64)
+		if err != nil || dst.OverflowInt(n) {
+			return false
+		}
+		dst.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(number, 10, 64)
+		if err != nil || dst.OverflowUint(n) {
+			return false
+		}
+		dst.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(number, dst.Type().Bits())
+		if err != nil || dst.OverflowFloat(n) {
+			return false
+		}
+		dst.SetFloat(n)
+	default:
+		return false
+	}
+	return true
+}
+
+// numberText returns the text of a parsed number, whether it came from
+// JSON as a json.Number or from MessagePack as a native value.
+func numberText(v interface{}) (string, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		return n.String(), true
+	case int64:
+		return strconv.FormatInt(n, 10), true
+	case uint64:
+		return strconv.FormatUint(n, 10), true
+	case float32:
+		return strconv.FormatFloat(float64(n), 'g', -1, 32), true
+	case float64:
+		return strconv.FormatFloat(n, 'g', -1, 64), true
+	}
+	return "", false
+}
+
+// plain converts numbers parsed with UseNumber to float64, as decoding
+// into an empty interface does.
+func plain(v interface{}) interface{} {
+	switch value := v.(type) {
+	case json.Number:
+		f, _ := value.Float64()
+		return f
+	case []interface{}:
+		for i, item := range value {
+			value[i] = plain(item)
+		}
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = plain(item)
+		}
+	}
+	return v
+}
+
+func mismatch(v interface{}, typ reflect.Type, path string) error {
+	kind := "object"
+	switch v.(type) {
+	case string:
+		kind = "string"
+	case json.Number, int64, uint64, float32, float64:
+		kind = "number"
+	case []byte:
+		kind = "bytes"
+	case bool:
+		kind = "bool"
+	case []interface{}:
+		kind = "array"
+	}
+	return &json.UnmarshalTypeError{Value: kind, Type: typ, Field: path}
+}
+
+func unixTime(number json.Number, format TimeFormat) (interface{}, error) {
+	n, err := number.Int64()
+	if err != nil {
+		return nil, fmt.Errorf("json: %s is not a valid timestamp", number)
+	}
+
+	t := time.Unix(n, 0)
+	if format == TimeUnixMilli {
+		t = time.UnixMilli(n)
+	}
+	return t.UTC().Format(time.RFC3339Nano), nil
+}
+
+// marshalerOf returns the value to hand to the engine when it marshals
+// itself. Like encoding/json, pointer receivers are used for addressable
+// values, so the pointer is returned in that case.
+func marshalerOf(value reflect.Value) (interface{}, bool) {
+	typ := value.Type()
+	if typ.Implements(jsonMarshalType) || typ.Implements(textMarshalType) {
+		if typ.Kind() == reflect.Ptr && value.IsNil() {
+			return nil, false
+		}
+		return value.Interface(), true
+	}
+
+	if value.CanAddr() {
+		pointer := reflect.PointerTo(typ)
+		if pointer.Implements(jsonMarshalType) || pointer.Implements(textMarshalType) {
+			return value.Addr().Interface(), true
+		}
+	}
+	return nil, false
+}
+
+// fieldByIndex is reflect.Value.FieldByIndex without panicking on nil
+// embedded pointers.
+func fieldByIndex(value reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				return reflect.Value{}, false
+			}
+			value = value.Elem()
+		}
+		value = value.Field(x)
+	}
+	return value, true
+}
+
+func mapKey(key reflect.Value) (string, error) {
+	if key.Kind() == reflect.String {
+		return key.String(), nil
+	}
+
+	if marshaler, ok := key.Interface().(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		return string(text), err
+	}
+
+	switch key.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(key.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("json: unsupported map key type %s", key.Type())
+}
+
+// formatNumber writes numbers from their kind, so String methods on named
+// number types are ignored, and floats are formatted as encoding/json does.
+func formatNumber(value reflect.Value) (string, error) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(value.Uint(), 10), nil
+	case reflect.Float32:
+		data, err := json.Marshal(float32(value.Float()))
+		return string(data), err
+	}
+
+	data, err := json.Marshal(value.Float())
+	return string(data), err
+}
+
+func isNumber(value reflect.Value) bool {
+	if value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// quotable reports whether the ,string option applies to a field type.
+// Like encoding/json, it covers scalars and unnamed pointers to them.
+func quotable(typ reflect.Type) bool {
+	if typ.Name() == "" && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func isNull(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		return value.IsNil()
+	}
+	return false
+}
+
+// isEmpty follows the omitempty rules of encoding/json, under which
+// structs, including time.Time, are never empty.
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return value.Len() == 0
+	case reflect.Bool:
+		return !value.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return value.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return value.Float() == 0
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil()
+	}
+	return false
+}
+
+type zeroer interface {
+	IsZero() bool
+}
+
+// isZero follows the omitzero rules of encoding/json, preferring an
+// IsZero method when the type has one.
+func isZero(value reflect.Value) bool {
+	if zero, ok := value.Interface().(zeroer); ok {
+		if value.Kind() == reflect.Ptr && value.IsNil() {
+			return true
+		}
+		return zero.IsZero()
+	}
+
+	if value.CanAddr() {
+		if zero, ok := value.Addr().Interface().(zeroer); ok {
+			return zero.IsZero()
+		}
+	}
+	return value.IsZero()
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// SnakeCase turns UserID into user_id.
+func SnakeCase(field string) string {
+	return strings.Join(words(field, strings.ToLower), "_")
+}
+
+// KebabCase turns UserID into user-id.
+func KebabCase(field string) string {
+	return strings.Join(words(field, strings.ToLower), "-")
+}
+
+// CamelCase turns UserID into userId.
+func CamelCase(field string) string {
+	parts := words(field, strings.ToLower)
+	for i := 1; i < len(parts); i++ {
+		parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+	}
+	return strings.Join(parts, "")
+}
+
+// words splits an identifier at case changes, keeping acronyms together:
+// HTTPServerID becomes HTTP, Server, ID.
+func words(field string, transform func(string) string) []string {
+	runes := []rune(field)
+	parts := make([]string, 0, 4)
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		prev, current := runes[i-1], runes[i]
+		lowerToUpper := !unicode.IsUpper(prev) && unicode.IsUpper(current)
+		acronymEnd := unicode.IsUpper(prev) && unicode.IsUpper(current) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+		if lowerToUpper || acronymEnd || current == '_' {
+			if part := strings.Trim(string(runes[start:i]), "_"); part != "" {
+				parts = append(parts, transform(part))
+			}
+			start = i
+		}
+	}
+
+	if part := strings.Trim(string(runes[start:]), "_"); part != "" {
+		parts = append(parts, transform(part))
+	}
+	return parts
+}
@@ -0,0 +1,227 @@
+package serialization
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// identity forces the reflective encoder and decoder without renaming, so
+// their output can be compared with encoding/json.
+var identity = &JSONOptions{Naming: func(field string) string { return field }}
+
+type level int
+
+func (l level) String() string { return "level-" + strconv.Itoa(int(l)) }
+
+type pointerMarshaler struct{ N int }
+
+func (p *pointerMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`"p` + strconv.Itoa(p.N) + `"`), nil
+}
+
+type Inner struct {
+	ID    int
+	Name  string `json:"name"`
+	Shade string
+}
+
+type Other struct {
+	Shade string
+}
+
+type sample struct {
+	Inner
+	*Other
+	Title    string            `json:"title,omitempty"`
+	Created  time.Time         `json:"created,omitempty"`
+	Nested   Inner             `json:"nested,omitempty"`
+	Zero     Inner             `json:"zero,omitzero"`
+	Level    level             `json:"level"`
+	Levels   map[level]int     `json:"levels"`
+	Quoted   int64             `json:"quoted,string"`
+	Flag     bool              `json:"flag,string"`
+	Ptr      *int              `json:"ptr,string"`
+	Ratio    float32           `json:"ratio"`
+	Big      float64           `json:"big"`
+	Data     []byte            `json:"data"`
+	Marshal  pointerMarshaler  `json:"marshal"`
+	Any      interface{}       `json:"any"`
+	Empty    []string          `json:"empty,omitempty"`
+	Nil      []string          `json:"nil"`
+	Count    uint8             `json:"count,omitempty"`
+	Array    [2]int            `json:"array"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Skipped  string            `json:"-"`
+	internal string
+}
+
+func TestJSONMatchesEncodingJSON(t *testing.T) {
+	seven := 7
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{"zero struct", sample{}},
+		{"filled struct", sample{
+			Inner:    Inner{ID: 1, Name: "a"},
+			Other:    &Other{Shade: "red"},
+			Title:    "t",
+			Created:  time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+			Nested:   Inner{ID: 2},
+			Level:    3,
+			Levels:   map[level]int{1: 10, 20: 2},
+			Quoted:   1 << 60,
+			Flag:     true,
+			Ptr:      &seven,
+			Ratio:    0.1,
+			Big:      1e21,
+			Data:     []byte("hello"),
+			Marshal:  pointerMarshaler{N: 4},
+			Any:      map[string]interface{}{"k": []interface{}{1.5, "v"}},
+			Empty:    []string{},
+			Count:    9,
+			Array:    [2]int{1, 2},
+			Labels:   map[string]string{"b": "2", "a": "1"},
+			internal: "hidden",
+		}},
+		{"pointer", &sample{Level: 1}},
+		{"slice", []level{1, 2}},
+		{"int keys", map[int64]string{-1: "a", 3: "b"}},
+		{"uint keys", map[uint16]bool{8: true}},
+		{"float", 3.25},
+		{"small float", float32(1e-7)},
+		{"nil", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := json.Marshal(tt.value)
+			if err != nil {
+				t.Fatalf("encoding/json: %v", err)
+			}
+
+			var got bytes.Buffer
+			if err := (JSON{Options: identity}).Serialize(&got, tt.value); err != nil {
+				t.Fatalf("serialize: %v", err)
+			}
+
+			if got.String() != string(want) {
+				t.Errorf("got  %s\nwant %s", got.String(), want)
+			}
+		})
+	}
+}
+
+func TestJSONDecodeMatchesEncodingJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", `{}`},
+		{"fields", `{"ID":1,"name":"a","Shade":"red","title":"t","created":"2024-05-01T10:00:00Z","level":3}`},
+		{"case insensitive", `{"id":5,"NAME":"b","TITLE":"u"}`},
+		{"quoted", `{"quoted":"1152921504606846976","flag":"true","ptr":"7"}`},
+		{"maps", `{"levels":{"1":10,"20":2},"labels":{"a":"1"}}`},
+		{"bytes and arrays", `{"data":"aGVsbG8=","array":[4],"empty":["x"],"nil":null}`},
+		{"any", `{"any":{"k":[1.5,"v",null,true]},"ratio":0.1,"big":1e21,"count":255}`},
+		{"nulls", `{"nested":null,"ptr":null,"any":null}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var want sample
+			if err := json.Unmarshal([]byte(tt.input), &want); err != nil {
+				t.Fatalf("encoding/json: %v", err)
+			}
+
+			var got sample
+			if err := (JSON{Options: identity}).Deserialize(strings.NewReader(tt.input), &got); err != nil {
+				t.Fatalf("deserialize: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got  %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestJSONDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"overflow", `{"count":256}`},
+		{"fraction into int", `{"level":1.5}`},
+		{"string into int", `{"level":"1"}`},
+		{"number into string", `{"title":1}`},
+		{"trailing data", `{} {}`},
+		{"syntax", `{"title":`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value sample
+			if err := (JSON{Options: identity}).Deserialize(strings.NewReader(tt.input), &value); err == nil {
+				t.Errorf("expected an error for %s", tt.input)
+			}
+
+			if err := json.Unmarshal([]byte(tt.input), &value); err == nil {
+				t.Errorf("encoding/json accepted %s", tt.input)
+			}
+		})
+	}
+}
+
+func TestJSONNumbersAsStrings(t *testing.T) {
+	opts := &JSONOptions{NumbersAsStrings: true}
+	value := struct {
+		Level level              `json:"level"`
+		Big   uint64             `json:"big"`
+		Ratio float32            `json:"ratio"`
+		Keys  map[level]struct{} `json:"keys"`
+	}{Level: 2, Big: 1<<64 - 1, Ratio: 0.1, Keys: map[level]struct{}{5: {}}}
+
+	var out bytes.Buffer
+	if err := (JSON{Options: opts}).Serialize(&out, value); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"level":"2","big":"18446744073709551615","ratio":"0.1","keys":{"5":{}}}`
+	if out.String() != want {
+		t.Fatalf("got  %s\nwant %s", out.String(), want)
+	}
+
+	decoded := value
+	decoded.Level, decoded.Big, decoded.Ratio = 0, 0, 0
+	if err := (JSON{Options: opts}).Deserialize(&out, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(decoded, value) {
+		t.Errorf("got %+v, want %+v", decoded, value)
+	}
+}
+
+func TestJSONFieldsPerOptions(t *testing.T) {
+	prefix := func(p string) NamingPolicy {
+		return func(field string) string { return p + field }
+	}
+
+	value := struct{ Name string }{"x"}
+	for _, p := range []string{"a_", "b_"} {
+		var out bytes.Buffer
+		if err := (JSON{Options: &JSONOptions{Naming: prefix(p)}}).Serialize(&out, value); err != nil {
+			t.Fatal(err)
+		}
+
+		if want := `{"` + p + `Name":"x"}`; out.String() != want {
+			t.Errorf("got %s, want %s", out.String(), want)
+		}
+	}
+}
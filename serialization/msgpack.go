@@ -0,0 +1,480 @@
+package serialization
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// MessagePack encodes values by reflection with their native types, so
+// integers keep their width and []byte is written as binary. Field names,
+// omission and the time format follow the JSON options and `json` tags;
+// quoting numbers as strings does not apply. Types with a custom JSON
+// marshaler are written through their JSON representation.
+type MessagePack struct{}
+
+func (MessagePack) Serialize(w io.Writer, v interface{}) error {
+	encoder := &msgpackEncoder{codec: currentJSON(), w: bufio.NewWriter(w)}
+	if err := encoder.encode(reflect.ValueOf(v)); err != nil {
+		return err
+	}
+	return encoder.w.Flush()
+}
+
+func (MessagePack) Deserialize(r io.Reader, v interface{}) error {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return fmt.Errorf("msgpack: cannot decode into %T", v)
+	}
+
+	generic, err := readMsgpack(bufio.NewReader(r), 0)
+	if err != nil {
+		return err
+	}
+	return currentJSON().assign(target.Elem(), generic, "")
+}
+
+type msgpackEncoder struct {
+	codec *jsonCodec
+	w     *bufio.Writer
+}
+
+func (e *msgpackEncoder) encode(value reflect.Value) error {
+	if !value.IsValid() {
+		return e.w.WriteByte(0xc0)
+	}
+
+	if value.Type() == timeType {
+		return e.encodeTime(value.Interface().(time.Time))
+	}
+
+	if marshaler, ok := marshalerOf(value); ok {
+		return e.encodeMarshaler(marshaler)
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return e.w.WriteByte(0xc0)
+		}
+		return e.encode(value.Elem())
+	case reflect.Struct:
+		return e.encodeStruct(value)
+	case reflect.Map:
+		return e.encodeMap(value)
+	case reflect.Slice:
+		if value.IsNil() {
+			return e.w.WriteByte(0xc0)
+		}
+
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			data := value.Bytes()
+			writeLength(e.w, len(data), 0, 0, 0xc4, 0xc5, 0xc6)
+			_, err := e.w.Write(data)
+			return err
+		}
+		return e.encodeList(value)
+	case reflect.Array:
+		return e.encodeList(value)
+	case reflect.String:
+		return writeMsgpack(e.w, value.String())
+	case reflect.Bool:
+		return writeMsgpack(e.w, value.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return writeInt(e.w, value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return writeUint(e.w, value.Uint())
+	case reflect.Float32:
+		e.w.WriteByte(0xca)
+		return binary.Write(e.w, binary.BigEndian, float32(value.Float()))
+	case reflect.Float64:
+		e.w.WriteByte(0xcb)
+		return binary.Write(e.w, binary.BigEndian, value.Float())
+	}
+	return fmt.Errorf("msgpack: cannot encode %s", value.Type())
+}
+
+func (e *msgpackEncoder) encodeTime(t time.Time) error {
+	switch e.codec.opts.TimeFormat {
+	case TimeUnix:
+		return writeInt(e.w, t.Unix())
+	case TimeUnixMilli:
+		return writeInt(e.w, t.UnixMilli())
+	}
+
+	text, err := t.MarshalText()
+	if err != nil {
+		return err
+	}
+	return writeMsgpack(e.w, string(text))
+}
+
+// encodeMarshaler writes text marshalers as strings and JSON marshalers as
+// the msgpack equivalent of the JSON they produce.
+func (e *msgpackEncoder) encodeMarshaler(marshaler interface{}) error {
+	if _, ok := marshaler.(json.Marshaler); !ok {
+		text, err := marshaler.(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return err
+		}
+		return writeMsgpack(e.w, string(text))
+	}
+
+	data, err := e.codec.opts.engine().Marshal(marshaler)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return err
+	}
+	return writeMsgpack(e.w, generic)
+}
+
+func (e *msgpackEncoder) encodeStruct(value reflect.Value) error {
+	fields := make([]jsonField, 0, value.NumField())
+	values := make([]reflect.Value, 0, value.NumField())
+	for _, field := range e.codec.jsonFields(value.Type()) {
+		fieldValue, ok := fieldByIndex(value, field.index)
+		if !ok {
+			continue
+		}
+
+		if field.omitEmpty && isEmpty(fieldValue) || field.omitZero && isZero(fieldValue) || e.codec.opts.OmitNulls && isNull(fieldValue) {
+			continue
+		}
+
+		fields = append(fields, field)
+		values = append(values, fieldValue)
+	}
+
+	writeLength(e.w, len(fields), 0x80, 16, 0, 0xde, 0xdf)
+	for i, field := range fields {
+		if err := writeMsgpack(e.w, field.name); err != nil {
+			return err
+		}
+
+		if err := e.encode(values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) encodeMap(value reflect.Value) error {
+	if value.IsNil() {
+		return e.w.WriteByte(0xc0)
+	}
+
+	keys := make([]string, 0, value.Len())
+	values := make(map[string]reflect.Value, value.Len())
+	iter := value.MapRange()
+	for iter.Next() {
+		key, err := mapKey(iter.Key())
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		values[key] = iter.Value()
+	}
+	slices.Sort(keys)
+
+	writeLength(e.w, len(keys), 0x80, 16, 0, 0xde, 0xdf)
+	for _, key := range keys {
+		if err := writeMsgpack(e.w, key); err != nil {
+			return err
+		}
+
+		if err := e.encode(values[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) encodeList(value reflect.Value) error {
+	writeLength(e.w, value.Len(), 0x90, 16, 0, 0xdc, 0xdd)
+	for i := 0; i < value.Len(); i++ {
+		if err := e.encode(value.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeMsgpack(w *bufio.Writer, v interface{}) error {
+	switch value := v.(type) {
+	case nil:
+		return w.WriteByte(0xc0)
+	case bool:
+		if value {
+			return w.WriteByte(0xc3)
+		}
+		return w.WriteByte(0xc2)
+	case json.Number:
+		return writeNumber(w, value)
+	case string:
+		writeLength(w, len(value), 0xa0, 32, 0xd9, 0xda, 0xdb)
+		_, err := w.WriteString(value)
+		return err
+	case []interface{}:
+		writeLength(w, len(value), 0x90, 16, 0, 0xdc, 0xdd)
+		for _, item := range value {
+			if err := writeMsgpack(w, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		writeLength(w, len(value), 0x80, 16, 0, 0xde, 0xdf)
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			if err := writeMsgpack(w, key); err != nil {
+				return err
+			}
+			if err := writeMsgpack(w, value[key]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("msgpack: cannot encode %T", v)
+}
+
+func writeNumber(w *bufio.Writer, number json.Number) error {
+	if i, err := number.Int64(); err == nil {
+		return writeInt(w, i)
+	}
+
+	if u, err := strconv.ParseUint(number.String(), 10, 64); err == nil {
+		return writeUint(w, u)
+	}
+
+	f, err := number.Float64()
+	if err != nil {
+		return err
+	}
+	w.WriteByte(0xcb)
+	return binary.Write(w, binary.BigEndian, f)
+}
+
+// writeInt uses the smallest format that holds i.
+func writeInt(w *bufio.Writer, i int64) error {
+	switch {
+	case i >= 0 && i <= math.MaxInt8:
+		return w.WriteByte(byte(i))
+	case i < 0 && i >= -32:
+		return w.WriteByte(byte(int8(i)))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		w.WriteByte(0xd0)
+		return w.WriteByte(byte(int8(i)))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		w.WriteByte(0xd1)
+		return binary.Write(w, binary.BigEndian, int16(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		w.WriteByte(0xd2)
+		return binary.Write(w, binary.BigEndian, int32(i))
+	default:
+		w.WriteByte(0xd3)
+		return binary.Write(w, binary.BigEndian, i)
+	}
+}
+
+func writeUint(w *bufio.Writer, u uint64) error {
+	switch {
+	case u <= math.MaxInt8:
+		return w.WriteByte(byte(u))
+	case u <= math.MaxUint8:
+		w.WriteByte(0xcc)
+		return w.WriteByte(byte(u))
+	case u <= math.MaxUint16:
+		w.WriteByte(0xcd)
+		return binary.Write(w, binary.BigEndian, uint16(u))
+	case u <= math.MaxUint32:
+		w.WriteByte(0xce)
+		return binary.Write(w, binary.BigEndian, uint32(u))
+	}
+
+	w.WriteByte(0xcf)
+	return binary.Write(w, binary.BigEndian, u)
+}
+
+// writeLength writes the header of a string, array or map. fixLimit is the
+// exclusive bound of the fix format; a zero code8 means there is no 8-bit
+// length variant.
+func writeLength(w *bufio.Writer, n int, fix byte, fixLimit int, code8, code16, code32 byte) {
+	switch {
+	case n < fixLimit:
+		w.WriteByte(fix | byte(n))
+	case code8 != 0 && n <= math.MaxUint8:
+		w.WriteByte(code8)
+		w.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		w.WriteByte(code16)
+		binary.Write(w, binary.BigEndian, uint16(n))
+	default:
+		w.WriteByte(code32)
+		binary.Write(w, binary.BigEndian, uint32(n))
+	}
+}
+
+// maxMsgpackDepth bounds the nesting of arrays and maps, as in
+// encoding/json, so hostile input cannot exhaust the stack.
+const maxMsgpackDepth = 10000
+
+func readMsgpack(r *bufio.Reader, depth int) (interface{}, error) {
+	if depth > maxMsgpackDepth {
+		return nil, fmt.Errorf("msgpack: exceeded max depth of %d", maxMsgpackDepth)
+	}
+
+	code, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case code <= 0x7f:
+		return int64(code), nil
+	case code >= 0xe0:
+		return int64(int8(code)), nil
+	case code&0xe0 == 0xa0:
+		return readString(r, int(code&0x1f))
+	case code&0xf0 == 0x90:
+		return readArray(r, int(code&0x0f), depth+1)
+	case code&0xf0 == 0x80:
+		return readMap(r, int(code&0x0f), depth+1)
+	}
+
+	switch code {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := readUint(r, 1<<(code-0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return readBytes(r, int(n))
+	case 0xca:
+		var f float32
+		err := binary.Read(r, binary.BigEndian, &f)
+		return f, err
+	case 0xcb:
+		var f float64
+		err := binary.Read(r, binary.BigEndian, &f)
+		return f, err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return readUint(r, 1<<(code-0xcc))
+	case 0xd0:
+		var i int8
+		err := binary.Read(r, binary.BigEndian, &i)
+		return int64(i), err
+	case 0xd1:
+		var i int16
+		err := binary.Read(r, binary.BigEndian, &i)
+		return int64(i), err
+	case 0xd2:
+		var i int32
+		err := binary.Read(r, binary.BigEndian, &i)
+		return int64(i), err
+	case 0xd3:
+		var i int64
+		err := binary.Read(r, binary.BigEndian, &i)
+		return i, err
+	case 0xd9, 0xda, 0xdb:
+		n, err := readUint(r, 1<<(code-0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return readString(r, int(n))
+	case 0xdc, 0xdd:
+		n, err := readUint(r, 2<<(code-0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return readArray(r, int(n), depth+1)
+	case 0xde, 0xdf:
+		n, err := readUint(r, 2<<(code-0xde))
+		if err != nil {
+			return nil, err
+		}
+		return readMap(r, int(n), depth+1)
+	}
+	return nil, fmt.Errorf("msgpack: unsupported type code 0x%02x", code)
+}
+
+func readUint(r *bufio.Reader, size int) (uint64, error) {
+	var buffer [8]byte
+	if _, err := io.ReadFull(r, buffer[8-size:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(buffer[:]), nil
+}
+
+// readBytes reads n bytes without trusting n for the allocation, since it
+// comes from the client.
+func readBytes(r *bufio.Reader, n int) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, int64(n)))
+	if err == nil && len(data) < n {
+		err = io.ErrUnexpectedEOF
+	}
+	return data, err
+}
+
+func readString(r *bufio.Reader, n int) (string, error) {
+	data, err := readBytes(r, n)
+	return string(data), err
+}
+
+func readArray(r *bufio.Reader, n, depth int) ([]interface{}, error) {
+	items := make([]interface{}, 0, min(n, 1024))
+	for i := 0; i < n; i++ {
+		item, err := readMsgpack(r, depth)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func readMap(r *bufio.Reader, n, depth int) (map[string]interface{}, error) {
+	items := make(map[string]interface{}, min(n, 1024))
+	for i := 0; i < n; i++ {
+		key, err := readMsgpack(r, depth)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := readMsgpack(r, depth)
+		if err != nil {
+			return nil, err
+		}
+		if data, ok := key.([]byte); ok {
+			key = string(data)
+		}
+		items[fmt.Sprint(key)] = value
+	}
+	return items, nil
+}
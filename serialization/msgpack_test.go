@@ -0,0 +1,148 @@
+package serialization
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type msgpackSample struct {
+	Int8    int8              `json:"int8"`
+	Int64   int64             `json:"int64"`
+	Uint64  uint64            `json:"uint64"`
+	Float32 float32           `json:"float32"`
+	Float64 float64           `json:"float64"`
+	Level   level             `json:"level"`
+	Data    []byte            `json:"data"`
+	Text    string            `json:"text"`
+	Flag    bool              `json:"flag"`
+	When    time.Time         `json:"when"`
+	Ptr     *Inner            `json:"ptr"`
+	List    []int16           `json:"list"`
+	Keys    map[int]string    `json:"keys"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Skipped string            `json:"-"`
+}
+
+func TestMessagePackRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{"zero", &msgpackSample{}},
+		{"filled", &msgpackSample{
+			Int8:    math.MinInt8,
+			Int64:   math.MinInt64,
+			Uint64:  math.MaxUint64,
+			Float32: 0.1,
+			Float64: 1e300,
+			Level:   7,
+			Data:    []byte{0, 1, 2, 255},
+			Text:    string(bytes.Repeat([]byte("x"), 300)),
+			Flag:    true,
+			When:    time.Date(2024, 5, 1, 10, 0, 0, 123, time.UTC),
+			Ptr:     &Inner{ID: 3, Name: "n"},
+			List:    []int16{-32768, 0, 32767},
+			Keys:    map[int]string{-1: "a", 40000: "b"},
+			Labels:  map[string]string{"k": "v"},
+		}},
+		{"slice", &[]uint16{0, 200, 65535}},
+		{"bytes", &[]byte{9, 8, 7}},
+		{"map", &map[string]float32{"a": 1.5, "b": -0.25}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			if err := (MessagePack{}).Serialize(&buffer, tt.value); err != nil {
+				t.Fatalf("serialize: %v", err)
+			}
+
+			decoded := reflect.New(reflect.TypeOf(tt.value).Elem())
+			if err := (MessagePack{}).Deserialize(&buffer, decoded.Interface()); err != nil {
+				t.Fatalf("deserialize: %v", err)
+			}
+
+			if !reflect.DeepEqual(decoded.Interface(), tt.value) {
+				t.Errorf("got  %+v\nwant %+v", decoded.Elem(), reflect.ValueOf(tt.value).Elem())
+			}
+		})
+	}
+}
+
+func TestMessagePackNativeTypes(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  []byte
+	}{
+		{"positive fixint", 5, []byte{0x05}},
+		{"negative fixint", -3, []byte{0xfd}},
+		{"uint8", uint8(200), []byte{0xcc, 0xc8}},
+		{"int16", int16(-300), []byte{0xd1, 0xfe, 0xd4}},
+		{"uint64", uint64(math.MaxUint64), []byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{"float32", float32(1.5), []byte{0xca, 0x3f, 0xc0, 0x00, 0x00}},
+		{"float64", 1.5, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"bytes", []byte{1, 2}, []byte{0xc4, 0x02, 0x01, 0x02}},
+		{"named number", level(1), []byte{0x01}},
+		{"nil slice", []int(nil), []byte{0xc0}},
+		{"struct", struct {
+			A int    `json:"a"`
+			B string `json:"b,omitempty"`
+		}{A: 1}, []byte{0x81, 0xa1, 'a', 0x01}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			if err := (MessagePack{}).Serialize(&buffer, tt.value); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(buffer.Bytes(), tt.want) {
+				t.Errorf("got % x, want % x", buffer.Bytes(), tt.want)
+			}
+		})
+	}
+}
+
+func TestMessagePackInterfaceKeepsTypes(t *testing.T) {
+	var buffer bytes.Buffer
+	value := map[string]interface{}{"int": int64(-5), "uint": uint64(math.MaxUint64), "float": float32(0.5), "data": []byte("x")}
+	if err := (MessagePack{}).Serialize(&buffer, value); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded interface{}
+	if err := (MessagePack{}).Deserialize(&buffer, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{"int": int64(-5), "uint": uint64(math.MaxUint64), "float": float32(0.5), "data": []byte("x")}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Errorf("got %#v, want %#v", decoded, want)
+	}
+}
+
+func TestMessagePackDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{"overflow", []byte{0x81, 0xa4, 'i', 'n', 't', '8', 0xcc, 0xc8}},
+		{"string into number", []byte{0x81, 0xa4, 'i', 'n', 't', '8', 0xa1, '1'}},
+		{"truncated", []byte{0x81, 0xa4, 'i', 'n'}},
+		{"unknown code", []byte{0xc1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value msgpackSample
+			if err := (MessagePack{}).Deserialize(bytes.NewReader(tt.input), &value); err == nil {
+				t.Errorf("expected an error for % x", tt.input)
+			}
+		})
+	}
+}
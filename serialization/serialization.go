@@ -0,0 +1,199 @@
+package serialization
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Serializer converts values to and from one wire format.
+type Serializer interface {
+	Serialize(w io.Writer, v interface{}) error
+	Deserialize(r io.Reader, v interface{}) error
+}
+
+// DefaultMediaType is used when the client sends no Accept header or a
+// request has no Content-Type.
+const DefaultMediaType = "application/json"
+
+var (
+	ErrNotAcceptable        = errors.New("none of the accepted media types is supported")
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+)
+
+type entry struct {
+	mediaType  string
+	serializer Serializer
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []entry
+)
+
+// Register adds a serializer for mediaType, replacing any previous one.
+// Serializers registered first win when a client accepts several of them
+// with the same preference.
+func Register(mediaType string, serializer Serializer) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	mediaType = strings.ToLower(mediaType)
+	for i, e := range registry {
+		if e.mediaType == mediaType {
+			registry[i].serializer = serializer
+			return
+		}
+	}
+	registry = append(registry, entry{mediaType: mediaType, serializer: serializer})
+}
+
+// Lookup returns the serializer for a Content-Type header value. Structured
+// syntax suffixes such as "+json" and "+xml" fall back to the serializer of
+// the base format.
+func Lookup(contentType string) (Serializer, error) {
+	mediaType := DefaultMediaType
+	if contentType != "" {
+		parsed, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, ErrUnsupportedMediaType
+		}
+		mediaType = parsed
+	}
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	if s, ok := find(mediaType); ok {
+		return s, nil
+	}
+
+	if i := strings.LastIndexByte(mediaType, '+'); i >= 0 {
+		if s, ok := find("application/" + mediaType[i+1:]); ok {
+			return s, nil
+		}
+	}
+	return nil, ErrUnsupportedMediaType
+}
+
+func find(mediaType string) (Serializer, bool) {
+	for _, e := range registry {
+		if e.mediaType == mediaType {
+			return e.serializer, true
+		}
+	}
+	return nil, false
+}
+
+type mediaRange struct {
+	mediaType string
+	quality   float64
+}
+
+// Acceptable lists the registered media types that satisfy an Accept
+// header, most preferred first. Media ranges with the same quality and
+// specificity are ties, settled by registration order, so JSON wins them.
+func Acceptable(accept string) []string {
+	if strings.TrimSpace(accept) == "" {
+		accept = DefaultMediaType
+	}
+
+	ranges := parseAccept(accept)
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	result := make([]string, 0, len(registry))
+	for start := 0; start < len(ranges) && ranges[start].quality > 0; {
+		end := start + 1
+		for end < len(ranges) && ranges[end].quality == ranges[start].quality &&
+			specificity(ranges[end].mediaType) == specificity(ranges[start].mediaType) {
+			end++
+		}
+
+		for _, e := range registry {
+			if slices.Contains(result, e.mediaType) || excluded(ranges, e.mediaType) {
+				continue
+			}
+
+			if slices.ContainsFunc(ranges[start:end], func(r mediaRange) bool { return matches(r.mediaType, e.mediaType) }) {
+				result = append(result, e.mediaType)
+			}
+		}
+		start = end
+	}
+	return result
+}
+
+// parseAccept returns the media ranges ordered by preference: quality
+// first, then specificity, then the order in the header.
+func parseAccept(accept string) []mediaRange {
+	ranges := make([]mediaRange, 0, 4)
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = parsed
+			}
+		}
+		ranges = append(ranges, mediaRange{mediaType: mediaType, quality: quality})
+	}
+
+	slices.SortStableFunc(ranges, func(a, b mediaRange) int {
+		if a.quality != b.quality {
+			if a.quality > b.quality {
+				return -1
+			}
+			return 1
+		}
+		return specificity(b.mediaType) - specificity(a.mediaType)
+	})
+	return ranges
+}
+
+func specificity(mediaRange string) int {
+	switch {
+	case mediaRange == "*/*":
+		return 0
+	case strings.HasSuffix(mediaRange, "/*"):
+		return 1
+	default:
+		return 2
+	}
+}
+
+func matches(mediaRange, mediaType string) bool {
+	if mediaRange == "*/*" || mediaRange == mediaType {
+		return true
+	}
+
+	prefix, ok := strings.CutSuffix(mediaRange, "*")
+	return ok && strings.HasSuffix(prefix, "/") && strings.HasPrefix(mediaType, prefix)
+}
+
+// excluded reports whether mediaType was explicitly refused with q=0.
+func excluded(ranges []mediaRange, mediaType string) bool {
+	for _, r := range ranges {
+		if r.mediaType == mediaType {
+			return r.quality <= 0
+		}
+	}
+	return false
+}
+
+// ContentType returns the Content-Type header value for mediaType.
+func ContentType(mediaType string) string {
+	if strings.HasPrefix(mediaType, "text/") {
+		return mediaType + "; charset=utf-8"
+	}
+	return mediaType
+}
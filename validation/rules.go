@@ -0,0 +1,122 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+var (
+	alphaRegex    = regexp.MustCompile(`^[A-Za-z]+$`)
+	alphanumRegex = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+	numericRegex  = regexp.MustCompile(`^[-+]?[0-9]+(\.[0-9]+)?$`)
+)
+
+func init() {
+	RegisterRule("required", required)
+	RegisterRule("min", compare("min", func(v, p float64) bool { return v >= p }, "must be at least %s", "must have at least %s elements"))
+	RegisterRule("max", compare("max", func(v, p float64) bool { return v <= p }, "must be at most %s", "must have at most %s elements"))
+	RegisterRule("len", compare("len", func(v, p float64) bool { return v == p }, "must be exactly %s", "must have exactly %s elements"))
+	RegisterRule("gt", compare("gt", func(v, p float64) bool { return v > p }, "must be greater than %s", "must have more than %s elements"))
+	RegisterRule("lt", compare("lt", func(v, p float64) bool { return v < p }, "must be less than %s", "must have less than %s elements"))
+	RegisterRule("oneof", oneOf)
+	RegisterRule("email", stringRule(func(s string) bool {
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s
+	}, "must be a valid email address"))
+	RegisterRule("url", stringRule(func(s string) bool {
+		u, err := url.Parse(s)
+		return err == nil && u.Scheme != "" && u.Host != ""
+	}, "must be a valid URL"))
+	RegisterRule("uuid", stringRule(func(s string) bool {
+		_, err := uuid.Parse(s)
+		return err == nil
+	}, "must be a valid UUID"))
+	RegisterRule("alpha", stringRule(alphaRegex.MatchString, "must contain only letters"))
+	RegisterRule("alphanum", stringRule(alphanumRegex.MatchString, "must contain only letters and digits"))
+	RegisterRule("numeric", stringRule(numericRegex.MatchString, "must be numeric"))
+}
+
+func required(field reflect.Value, _ string) error {
+	if !field.IsValid() || field.IsZero() {
+		return errors.New("is required")
+	}
+
+	switch field.Kind() {
+	case reflect.Slice, reflect.Map:
+		if field.Len() == 0 {
+			return errors.New("is required")
+		}
+	}
+	return nil
+}
+
+// compare checks numbers by value and strings, slices and maps by length.
+func compare(name string, ok func(value, param float64) bool, valueMessage, lengthMessage string) Rule {
+	return func(field reflect.Value, param string) error {
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s parameter %q", name, param)
+		}
+
+		var value float64
+		message := valueMessage
+		switch field.Kind() {
+		case reflect.String:
+			value = float64(utf8.RuneCountInString(field.String()))
+			message = strings.Replace(lengthMessage, "elements", "characters", 1)
+		case reflect.Slice, reflect.Map, reflect.Array:
+			value = float64(field.Len())
+			message = lengthMessage
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			value = float64(field.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			value = float64(field.Uint())
+		case reflect.Float32, reflect.Float64:
+			value = field.Float()
+		case reflect.Ptr, reflect.Interface, reflect.Invalid:
+			return nil
+		default:
+			return fmt.Errorf("%s is not supported for %s", name, field.Type())
+		}
+
+		if !ok(value, limit) {
+			return fmt.Errorf(message, param)
+		}
+		return nil
+	}
+}
+
+func oneOf(field reflect.Value, param string) error {
+	if field.Kind() == reflect.Ptr || field.Kind() == reflect.Interface || !field.IsValid() {
+		return nil
+	}
+
+	options := strings.Fields(param)
+	if slices.Contains(options, fmt.Sprint(field.Interface())) {
+		return nil
+	}
+	return fmt.Errorf("must be one of [%s]", strings.Join(options, ", "))
+}
+
+func stringRule(valid func(string) bool, message string) Rule {
+	return func(field reflect.Value, _ string) error {
+		if field.Kind() != reflect.String {
+			return nil
+		}
+
+		if field.String() == "" || valid(field.String()) {
+			return nil
+		}
+		return errors.New(message)
+	}
+}
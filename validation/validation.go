@@ -0,0 +1,286 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Validator is implemented by types that check rules which cannot be
+// expressed with tags. It runs after the tag rules of the value.
+type Validator interface {
+	Validate() error
+}
+
+// Rule checks a single field. param is the text after "=" in the tag, e.g.
+// "3" for "min=3". A nil error means the field is valid.
+type Rule func(field reflect.Value, param string) error
+
+// FieldError describes one invalid field. Source is set by request
+// binding to the part of the request the field came from, such as "query".
+type FieldError struct {
+	Field   string `json:"field"`
+	Source  string `json:"source,omitempty"`
+	Rule    string `json:"rule,omitempty"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		if err.Field == "" {
+			messages = append(messages, err.Message)
+			continue
+		}
+		messages = append(messages, err.Field+": "+err.Message)
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+// ErrUnknownRule is returned when a tag names a rule that was never
+// registered. It is a programming error rather than invalid input.
+var ErrUnknownRule = errors.New("unknown validation rule")
+
+var (
+	rulesMu sync.RWMutex
+	rules   = map[string]Rule{}
+
+	validatorType = reflect.TypeOf((*Validator)(nil)).Elem()
+)
+
+// walk carries the result of a validation: the field errors, and the first
+// unknown rule, which fails the whole validation instead.
+type walk struct {
+	errs Errors
+	err  error
+}
+
+func RegisterRule(name string, rule Rule) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+
+	rules[name] = rule
+}
+
+// Validate checks v against its `validate` struct tags, descending into
+// nested structs, slices and maps, and calls Validate on every value
+// implementing Validator. It returns Errors when anything fails, or an
+// error wrapping ErrUnknownRule when a tag names an unknown rule.
+func Validate(v interface{}) error {
+	w := &walk{}
+	validateValue(reflect.ValueOf(v), "", w)
+	if w.err != nil {
+		return w.err
+	}
+
+	if len(w.errs) > 0 {
+		return w.errs
+	}
+	return nil
+}
+
+// CheckRules reports the unknown rules named by the `validate` tags of typ
+// and of the types it contains, so they can be caught at startup.
+func CheckRules(typ reflect.Type) error {
+	var errs []error
+	checkRules(typ, "", nil, &errs)
+	return errors.Join(errs...)
+}
+
+func checkRules(typ reflect.Type, path string, walking []reflect.Type, errs *[]error) {
+	for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array || typ.Kind() == reflect.Map {
+		typ = typ.Elem()
+	}
+
+	if typ.Kind() != reflect.Struct || slices.Contains(walking, typ) {
+		return
+	}
+	walking = append(walking, typ)
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		fieldPath := path
+		if !field.Anonymous {
+			fieldPath = joinPath(path, fieldName(field))
+		}
+
+		if tag, ok := field.Tag.Lookup("validate"); ok && tag != "-" {
+			for _, item := range strings.Split(tag, ",") {
+				name, _, _ := strings.Cut(strings.TrimSpace(item), "=")
+				if name != "" && name != "omitempty" && !ruleExists(name) {
+					*errs = append(*errs, fmt.Errorf("%w %q on %s", ErrUnknownRule, name, fieldPath))
+				}
+			}
+		}
+
+		checkRules(field.Type, fieldPath, walking, errs)
+	}
+}
+
+func ruleExists(name string) bool {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+
+	_, ok := rules[name]
+	return ok
+}
+
+func validateValue(value reflect.Value, path string, w *walk) {
+	if !value.IsValid() {
+		return
+	}
+
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		validateStruct(value, path, w)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			validateValue(value.Index(i), fmt.Sprintf("%s[%d]", path, i), w)
+		}
+	case reflect.Map:
+		iter := value.MapRange()
+		for iter.Next() {
+			validateValue(iter.Value(), fmt.Sprintf("%s[%v]", path, iter.Key()), w)
+		}
+	}
+
+	runValidator(value, path, w)
+}
+
+func validateStruct(value reflect.Value, path string, w *walk) {
+	typ := value.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		fieldValue := value.Field(i)
+		fieldPath := path
+		if !field.Anonymous {
+			fieldPath = joinPath(path, fieldName(field))
+		}
+
+		if tag, ok := field.Tag.Lookup("validate"); ok && tag != "-" {
+			if !applyRules(fieldValue, fieldPath, tag, w) {
+				continue
+			}
+		}
+
+		validateValue(fieldValue, fieldPath, w)
+	}
+}
+
+// applyRules runs the comma separated rules of tag and reports whether the
+// field should be validated further.
+func applyRules(field reflect.Value, path, tag string, w *walk) bool {
+	valid := true
+	for _, item := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(item), "=")
+		if name == "" {
+			continue
+		}
+
+		if name == "omitempty" {
+			if field.IsZero() {
+				return false
+			}
+			continue
+		}
+
+		rulesMu.RLock()
+		rule, ok := rules[name]
+		rulesMu.RUnlock()
+
+		if !ok {
+			if w.err == nil {
+				w.err = fmt.Errorf("%w %q on %s", ErrUnknownRule, name, path)
+			}
+			return false
+		}
+
+		if err := rule(indirect(field), param); err != nil {
+			w.errs = append(w.errs, FieldError{Field: path, Rule: name, Param: param, Message: err.Error()})
+			valid = false
+			if name == "required" {
+				return false
+			}
+		}
+	}
+	return valid
+}
+
+func runValidator(value reflect.Value, path string, w *walk) {
+	var validator Validator
+	if value.Type().Implements(validatorType) {
+		validator = value.Interface().(Validator)
+	} else if value.CanAddr() && value.Addr().Type().Implements(validatorType) {
+		validator = value.Addr().Interface().(Validator)
+	} else {
+		return
+	}
+
+	err := validator.Validate()
+	if err == nil {
+		return
+	}
+
+	var nested Errors
+	if errors.As(err, &nested) {
+		for _, e := range nested {
+			e.Field = joinPath(path, e.Field)
+			w.errs = append(w.errs, e)
+		}
+		return
+	}
+
+	w.errs = append(w.errs, FieldError{Field: path, Rule: "custom", Message: err.Error()})
+}
+
+// indirect dereferences pointers so that rules see the pointed-to value.
+// nil pointers are returned as is, so that "required" can reject them.
+func indirect(value reflect.Value) reflect.Value {
+	for (value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface) && !value.IsNil() {
+		value = value.Elem()
+	}
+	return value
+}
+
+func fieldName(field reflect.StructField) string {
+	for _, key := range []string{"json", "path", "query", "header", "form"} {
+		if tag, ok := field.Tag.Lookup(key); ok {
+			name, _, _ := strings.Cut(tag, ",")
+			if name != "" && name != "-" {
+				return name
+			}
+		}
+	}
+	return field.Name
+}
+
+func joinPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	if name == "" || strings.HasPrefix(name, "[") {
+		return parent + name
+	}
+	return parent + "." + name
+}